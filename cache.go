@@ -0,0 +1,265 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+)
+
+// A Cache stores the results of Tasks in a directory so that a Task
+// can be skipped when its inputs have not changed since it last succeeded
+type Cache struct {
+	Dir string
+}
+
+// NewCache returns a Cache that stores its data in dir
+// dir is created if it does not exist
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "objects"), 0755); err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: dir}, nil
+}
+
+// CacheInputs declares the inputs of a cached Task in addition to TaskInfo.Src
+type CacheInputs struct {
+	Key   string   // Identifies the Task in the Cache, set it when caching Tasks that differ only in their settings (See taskKey)
+	Globs []string // Glob patterns for additional input files
+	Env   []string // Names of environment variables used by the Task
+	Args  []string // Arguments or settings of the Task
+}
+
+// cacheEntry is the stored result of a successful Task run
+type cacheEntry struct {
+	Inputs string // hash of the inputs
+	Target string
+	Buf    []byte
+	Output string // hash of the Target contents, blank if the Target is not a stored file
+}
+
+type cacheTask struct {
+	cache *Cache
+	task  Tasker
+	in    CacheInputs
+}
+
+// NewCacheTask returns a Tasker that runs t only when the inputs of t have changed since it last succeeded
+// When t is skipped TaskInfo.Target and TaskInfo.Buf are set to the results of the last run
+// and the Target file is restored from the Cache if it is missing or has changed
+func NewCacheTask(c *Cache, t Tasker, in CacheInputs) Tasker {
+	if in.Key == "" {
+		in.Key = taskKey(t)
+	}
+	return &cacheTask{cache: c, task: t, in: in}
+}
+
+// taskKey returns the default Cache Key for t
+// It is the Task type, the Runner of a NewTask, the String of a fmt.Stringer
+// and the Target t gives a sample file so Tasks of one type do not share results
+func taskKey(t Tasker) string {
+	key := fmt.Sprintf("%T", t)
+	if tk, ok := t.(*task); ok && tk.RunFunc != nil {
+		if f := runtime.FuncForPC(reflect.ValueOf(tk.RunFunc).Pointer()); f != nil {
+			key += " " + f.Name()
+		}
+	}
+	if s, ok := t.(fmt.Stringer); ok {
+		key += " " + s.String()
+	}
+	if target, ok := targetOf(t, filepath.Join("goauto", "key.src")); ok {
+		key += " " + target
+	}
+	return key
+}
+
+func (ct *cacheTask) Run(info *TaskInfo) (err error) {
+	key := ct.cache.key(ct.in.Key, info.Src)
+	sum, herr := ct.inputs(info.Src)
+	if herr == nil {
+		if ent, ok := ct.cache.load(key); ok && ent.Inputs == sum {
+			if rerr := ct.cache.restore(ent); rerr == nil {
+				info.Target = ent.Target
+				info.Buf.Reset()
+				info.Buf.Write(ent.Buf)
+				if info.Verbose {
+					fmt.Fprintf(info.Tout, "<< %v up to date for %v\n", ct.in.Key, info.Src)
+				}
+				return nil
+			}
+		}
+	}
+
+	src := info.Src
+	if err = ct.task.Run(info); err != nil || herr != nil {
+		return
+	}
+	ent := cacheEntry{Inputs: sum, Target: info.Target, Buf: info.Buf.Bytes()}
+	var serr error
+	if info.Target != "" && info.Target != src {
+		ent.Output, serr = ct.cache.storeObject(info.Target)
+	}
+	if serr == nil {
+		serr = ct.cache.save(key, ent)
+	}
+	if serr != nil {
+		// the task worked, it just can not be skipped next time
+		fmt.Fprintf(info.Terr, "Cache %v: %v\n", ct.cache.Dir, serr)
+	}
+	return
+}
+
+// inputs returns a hash of everything the task depends on
+func (ct *cacheTask) inputs(src string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n", ct.in.Key)
+	if err := hashFile(h, src); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	var files []string
+	for _, g := range ct.in.Globs {
+		m, err := filepath.Glob(g)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, m...)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if err := hashFile(h, f); err != nil {
+			return "", err
+		}
+	}
+	for _, e := range ct.in.Env {
+		fmt.Fprintf(h, "%q=%q\n", e, os.Getenv(e))
+	}
+	for _, a := range ct.in.Args {
+		fmt.Fprintf(h, "%q\n", a)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile writes the name and contents of a file to h
+// directories contribute only their name
+func hashFile(h io.Writer, fpath string) error {
+	fmt.Fprintf(h, "%q\n", fpath)
+	fi, err := os.Stat(fpath)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return nil
+	}
+	f, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
+
+func fileSum(fpath string) (string, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *Cache) key(task, src string) string {
+	sum := sha256.Sum256([]byte(task + "\x00" + src))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) load(key string) (ent cacheEntry, ok bool) {
+	b, err := ioutil.ReadFile(filepath.Join(c.Dir, key+".json"))
+	if err != nil {
+		return
+	}
+	return ent, json.Unmarshal(b, &ent) == nil
+}
+
+func (c *Cache) save(key string, ent cacheEntry) error {
+	b, err := json.Marshal(ent)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(filepath.Join(c.Dir, key+".json"), b)
+}
+
+// storeObject copies a file into the Cache and returns the hash of its contents
+func (c *Cache) storeObject(fpath string) (string, error) {
+	fi, err := os.Stat(fpath)
+	if err != nil || fi.IsDir() {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	h := hex.EncodeToString(sum[:])
+	obj := filepath.Join(c.Dir, "objects", h)
+	if _, err = os.Stat(obj); err == nil {
+		return h, nil
+	}
+	return h, writeAtomic(obj, b)
+}
+
+// restore puts back the Target of an entry if it is missing or has changed
+func (c *Cache) restore(ent cacheEntry) error {
+	if ent.Output == "" {
+		return nil
+	}
+	if sum, err := fileSum(ent.Target); err == nil && sum == ent.Output {
+		return nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(c.Dir, "objects", ent.Output))
+	if err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(b); hex.EncodeToString(sum[:]) != ent.Output {
+		return fmt.Errorf("Cache object %v is corrupt", ent.Output)
+	}
+	return writeAtomic(ent.Target, b)
+}
+
+// writeAtomic writes a file by writing a temporary file and renaming it
+func writeAtomic(fpath string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(fpath), ".goauto")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(b); err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), fpath); err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheTask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := NewCache(filepath.Join(dir, ".cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "a.txt")
+	if err = ioutil.WriteFile(src, []byte("one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := 0
+	upper := NewTask(ExtTransformer("out"), func(i *TaskInfo) error {
+		runs++
+		i.Buf.Reset()
+		i.Buf.WriteString("built")
+		b, err := ioutil.ReadFile(i.Src)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(i.Target, append(b, '!'), 0644)
+	})
+	tsk := NewCacheTask(c, upper, CacheInputs{Key: "upper", Env: []string{"GOAUTO_TEST"}})
+	target := filepath.Join(dir, "a.out")
+
+	run := func(expect int) {
+		info := TaskInfo{Src: src, Tout: ioutil.Discard, Terr: ioutil.Discard}
+		if err := tsk.Run(&info); err != nil {
+			t.Fatal(err)
+		}
+		if runs != expect {
+			t.Errorf("Expected %v runs got %v", expect, runs)
+		}
+		if info.Target != target || info.Buf.String() != "built" {
+			t.Errorf("Expected %v and built got %v and %v", target, info.Target, info.Buf.String())
+		}
+	}
+
+	run(1)
+	run(1)
+
+	os.Remove(target)
+	run(1)
+	if b, _ := ioutil.ReadFile(target); string(b) != "one!" {
+		t.Errorf("Expected target to be restored got %q", b)
+	}
+
+	ioutil.WriteFile(src, []byte("two"), 0644)
+	run(2)
+
+	os.Setenv("GOAUTO_TEST", "changed")
+	defer os.Unsetenv("GOAUTO_TEST")
+	run(3)
+	run(3)
+}
+
+func TestCacheTaskKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := NewCache(filepath.Join(dir, ".cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "a.txt")
+	if err = ioutil.WriteFile(src, []byte("one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var runs []string
+	lint := NewCacheTask(c, NewTask(Identity, func(i *TaskInfo) error {
+		runs = append(runs, "lint")
+		i.Buf.WriteString("lint")
+		return nil
+	}), CacheInputs{})
+	count := NewCacheTask(c, NewTask(Identity, func(i *TaskInfo) error {
+		runs = append(runs, "count")
+		i.Buf.WriteString("count")
+		return nil
+	}), CacheInputs{})
+	gz := NewCacheTask(c, NewTask(ExtTransformer("gz"), nil), CacheInputs{})
+	if ct := gz.(*cacheTask); ct.in.Key == count.(*cacheTask).in.Key || ct.in.Key == lint.(*cacheTask).in.Key {
+		t.Errorf("Expected a Key for each Task got %v", ct.in.Key)
+	}
+
+	for i := 0; i < 2; i++ {
+		for name, tsk := range map[string]Tasker{"lint": lint, "count": count} {
+			info := TaskInfo{Src: src, Tout: ioutil.Discard, Terr: ioutil.Discard}
+			if err := tsk.Run(&info); err != nil {
+				t.Fatal(err)
+			}
+			if info.Buf.String() != name {
+				t.Errorf("Expected %v got %v", name, info.Buf.String())
+			}
+		}
+	}
+	if len(runs) != 2 || runs[0] == runs[1] {
+		t.Errorf("Expected each Task to run once got %v", runs)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ChainOn describes the Workflow results that trigger a chained Workflow
+type ChainOn uint8
+
+// Results of a Workflow that can trigger a chained Workflow
+const (
+	OnSuccess ChainOn = 1 << iota
+	OnFailure
+	OnAlways = OnSuccess | OnFailure
+)
+
+type chain struct {
+	on   ChainOn
+	next Workflower
+	join *fanIn
+}
+
+// fanIn waits for a set of Workflows to finish before triggering next
+type fanIn struct {
+	next Workflower
+	ups  []*Workflow
+	mu   sync.Mutex
+	done map[*Workflow][]string
+}
+
+// Chain will run next each time wf finishes with a result matching on
+// next is run with the TaskInfo.Collect of wf
+// An error is returned if next would in turn trigger wf
+func (wf *Workflow) Chain(on ChainOn, next Workflower) error {
+	if triggers(next, wf) {
+		return fmt.Errorf("Chaining %v to %v creates a cycle", wfName(next), wf.Name)
+	}
+	wf.chains = append(wf.chains, chain{on: on, next: next})
+	return nil
+}
+
+// ChainAll will run next once every Workflow in ups has finished with a result matching on
+// next is run with the combined TaskInfo.Collect of all the Workflows in ups
+// A Workflow finishing with a result that does not match on must run again before next is triggered
+// An error is returned if next would in turn trigger any of the Workflows in ups
+func ChainAll(on ChainOn, next Workflower, ups ...*Workflow) error {
+	for _, wf := range ups {
+		if triggers(next, wf) {
+			return fmt.Errorf("Chaining %v to %v creates a cycle", wfName(next), wf.Name)
+		}
+	}
+	f := &fanIn{next: next, ups: ups, done: make(map[*Workflow][]string)}
+	for _, wf := range ups {
+		wf.chains = append(wf.chains, chain{on: on, join: f})
+	}
+	return nil
+}
+
+// triggers checks if running from will eventually run wf
+func triggers(from Workflower, wf *Workflow) bool {
+	seen := make(map[Workflower]bool)
+	var walk func(w Workflower) bool
+	walk = func(w Workflower) bool {
+		if w == Workflower(wf) {
+			return true
+		}
+		if seen[w] {
+			return false
+		}
+		seen[w] = true
+		if cw, ok := w.(*Workflow); ok {
+			for _, c := range cw.chains {
+				next := c.next
+				if c.join != nil {
+					next = c.join.next
+				}
+				if walk(next) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return walk(from)
+}
+
+func wfName(w Workflower) string {
+	if wf, ok := w.(*Workflow); ok {
+		return wf.Name
+	}
+	return fmt.Sprintf("%T", w)
+}
+
+// fire runs the chained Workflows matching the result of a run of wf
+func (wf *Workflow) fire(info *TaskInfo, src string, err error) {
+	on := OnSuccess
+	if err != nil {
+		on = OnFailure
+	}
+	for _, c := range wf.chains {
+		if c.join != nil {
+			c.join.finished(wf, info, src, c.on&on != 0)
+			continue
+		}
+		if c.on&on == 0 {
+			continue
+		}
+		if info.Verbose {
+			fmt.Fprintf(info.Tout, ">> %v triggered by %v\n", wfName(c.next), wf.Name)
+		}
+		c.next.Run(chainInfo(info, src, info.Collect))
+	}
+}
+
+// finished records the result of one of the fan in Workflows
+// and runs next when all of them have finished
+func (f *fanIn) finished(wf *Workflow, info *TaskInfo, src string, ok bool) {
+	f.mu.Lock()
+	if !ok {
+		delete(f.done, wf)
+		f.mu.Unlock()
+		return
+	}
+	f.done[wf] = append([]string(nil), info.Collect...)
+	if len(f.done) < len(f.ups) {
+		f.mu.Unlock()
+		return
+	}
+	var collect []string
+	for _, up := range f.ups {
+		collect = append(collect, f.done[up]...)
+	}
+	f.done = make(map[*Workflow][]string)
+	f.mu.Unlock()
+
+	if info.Verbose {
+		fmt.Fprintf(info.Tout, ">> %v triggered by %v and %v others\n", wfName(f.next), wf.Name, len(f.ups)-1)
+	}
+	f.next.Run(chainInfo(info, src, collect))
+}
+
+// chainInfo returns a fresh TaskInfo for a chained Workflow
+func chainInfo(info *TaskInfo, src string, collect []string) *TaskInfo {
+	return &TaskInfo{
+		Src:      src,
+		Tout:     info.Tout,
+		Terr:     info.Terr,
+		Verbose:  info.Verbose,
+		Collect:  append([]string(nil), collect...),
+		pipeline: info.pipeline,
+		pass:     info.pass,
+	}
+}
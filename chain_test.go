@@ -0,0 +1,83 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+type collectTask struct {
+	collect *[]string
+}
+
+func (t collectTask) Run(i *TaskInfo) (err error) {
+	*t.collect = append([]string(nil), i.Collect...)
+	return
+}
+
+func TestChain(t *testing.T) {
+	var got []string
+	build := NewWorkflow(NewEmptyTask())
+	deploy := NewWorkflow(collectTask{&got})
+	broken := NewWorkflow(testTask{showError: true})
+	notify := NewWorkflow(collectTask{&got})
+
+	if err := build.Chain(OnSuccess, deploy); err != nil {
+		t.Fatal(err)
+	}
+	if err := broken.Chain(OnSuccess, deploy); err != nil {
+		t.Fatal(err)
+	}
+	if err := broken.Chain(OnFailure, notify); err != nil {
+		t.Fatal(err)
+	}
+
+	build.Run(&TaskInfo{Src: "a.go", Tout: ioutil.Discard, Terr: ioutil.Discard})
+	if len(got) != 2 || got[0] != "a.go" {
+		t.Errorf("Expected deploy to run with [a.go a.go] got %v", got)
+	}
+
+	got = nil
+	broken.Run(&TaskInfo{Src: "b.go", Tout: ioutil.Discard, Terr: ioutil.Discard})
+	if len(got) != 1 || got[0] != "b.go" {
+		t.Errorf("Expected only notify to run with [b.go] got %v", got)
+	}
+
+	if err := deploy.Chain(OnAlways, build); err == nil {
+		t.Errorf("Expected error for chain cycle")
+	}
+	if err := deploy.Chain(OnAlways, deploy); err == nil {
+		t.Errorf("Expected error for chaining a workflow to itself")
+	}
+}
+
+func TestChainAll(t *testing.T) {
+	var got []string
+	css := NewWorkflow(NewEmptyTask())
+	js := NewWorkflow(NewEmptyTask())
+	reload := NewWorkflow(collectTask{&got})
+	if err := ChainAll(OnSuccess, reload, css, js); err != nil {
+		t.Fatal(err)
+	}
+
+	css.Run(&TaskInfo{Src: "a.css", Tout: ioutil.Discard, Terr: ioutil.Discard})
+	if got != nil {
+		t.Errorf("Expected reload to wait for js, got %v", got)
+	}
+	js.Run(&TaskInfo{Src: "a.js", Tout: ioutil.Discard, Terr: ioutil.Discard})
+	if len(got) != 4 || got[0] != "a.css" || got[2] != "a.js" {
+		t.Errorf("Expected reload to run with both collects got %v", got)
+	}
+
+	got = nil
+	js.Run(&TaskInfo{Src: "b.js", Tout: ioutil.Discard, Terr: ioutil.Discard})
+	if got != nil {
+		t.Errorf("Expected reload to wait for css again, got %v", got)
+	}
+
+	if err := reload.Chain(OnSuccess, js); err == nil {
+		t.Errorf("Expected error for fan in cycle")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import "fmt"
+
+// a wrapper is a Tasker that runs another Tasker
+type wrapper interface {
+	unwrap() Tasker
+}
+
+func (t *resourceTask) unwrap() Tasker { return t.Tasker }
+func (ct *cacheTask) unwrap() Tasker   { return ct.task }
+func (ft *FreshTask) unwrap() Tasker   { return ft.Task }
+
+// targetOf returns the Target t would produce for src if it is known
+func targetOf(t Tasker, src string) (string, bool) {
+	for {
+		if tt, ok := t.(Targeter); ok {
+			return tt.Target(src), true
+		}
+		w, ok := t.(wrapper)
+		if !ok {
+			return "", false
+		}
+		t = w.unwrap()
+	}
+}
+
+// explain writes which Workflows match an Event and why, and the tasks that would run
+// Nothing is run
+func (p *Pipeline) explain(e *Event) {
+	w := p.Wout
+	fmt.Fprintf(w, "> Dry run %v %v\n", e.Op, e.Path)
+	for _, wf := range p.Workflows {
+		var match bool
+		var reason string
+		if ex, ok := wf.(Explainer); ok {
+			match, reason = ex.Explain(e.Path, e.Op)
+		} else if match = wf.Match(e.Path, e.Op); !match {
+			reason = "no match"
+		}
+		cw, ok := wf.(*Workflow)
+		if match && ok && !cw.Enabled() {
+			match, reason = false, "disabled"
+		}
+		if !match {
+			fmt.Fprintf(w, "  - %v: %v\n", wfName(wf), reason)
+			continue
+		}
+		fmt.Fprintf(w, "  + %v: %v\n", wfName(wf), reason)
+		if !ok {
+			continue
+		}
+		src := e.Path
+		for _, t := range cw.Tasks {
+			target, known := targetOf(t, src)
+			if !known {
+				fmt.Fprintf(w, "      %T %v -> ?\n", t, src)
+				continue
+			}
+			fmt.Fprintf(w, "      %T %v -> %v\n", t, src, target)
+			if target != "" {
+				src = target
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	var out bytes.Buffer
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Wout = &out
+	p.DryRun = true
+
+	ran := false
+	run := func(*TaskInfo) error {
+		ran = true
+		return nil
+	}
+	build := NewWorkflow(NewTask(ExtTransformer("css"), run), NewResourceTask(NewTask(ExtTransformer("min.css"), run), "css"), NewEmptyTask())
+	build.Name = "build"
+	build.WatchPattern(`\.scss$`)
+	js := NewWorkflow(NewTask(Identity, run))
+	js.Name = "js"
+	js.WatchPattern(`\.js$`)
+	create := NewWorkflow(NewTask(Identity, run))
+	create.Name = "create"
+	create.WatchPattern(`.*`)
+	off := NewWorkflow(NewTask(Identity, run))
+	off.Name = "off"
+	off.WatchPattern(`.*`)
+	off.Disable(DropEvents)
+	p.Add(build, js, create, off)
+
+	p.run(&Event{Path: "a.scss", Op: Write}, nil)
+	p.run(&Event{Path: "b.txt", Op: Chmod}, nil)
+	if ran {
+		t.Errorf("Expected dry run not to run tasks")
+	}
+	for _, e := range []string{
+		"Dry run Write a.scss",
+		"+ build: matched \\.scss$",
+		"a.scss -> a.css",
+		"a.css -> a.min.css",
+		"*goauto.emptyTask a.min.css -> ?",
+		"- js: no pattern matched",
+		"- create: op Chmod is not one of Create|Write|Remove|Rename",
+		"- off: disabled",
+	} {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("Expected %q in output\n%v", e, out.String())
+		}
+	}
+}
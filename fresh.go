@@ -0,0 +1,146 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// A Targeter is a Tasker that knows the Target it will produce for a Src
+// Tasks built with NewTask are Targeters
+type Targeter interface {
+	Tasker
+	Target(src string) string
+}
+
+// Target returns the Target the task will produce for src
+func (t *task) Target(src string) string {
+	return t.Transform(src)
+}
+
+// A FreshTask runs Task only when Transform(TaskInfo.Src) is out of date
+// The Target is out of date when it is missing or when TaskInfo.Src or one of the Prereqs is newer
+// When Hash is set the contents of the files are compared with the last successful run rather than modification times
+type FreshTask struct {
+	Task      Tasker
+	Transform Transformer
+	Prereqs   []string // Glob patterns of additional files the Target depends on
+	Hash      bool
+	mu        sync.Mutex
+	sums      map[string]string
+}
+
+// NewFreshTask returns a FreshTask for a Targeter such as a Tasker built with NewTask
+// Tasks that are not Targeters will always run
+func NewFreshTask(t Tasker, prereqs ...string) *FreshTask {
+	tr := Identity
+	if tt, ok := t.(Targeter); ok {
+		tr = tt.Target
+	}
+	return &FreshTask{Task: t, Transform: tr, Prereqs: prereqs}
+}
+
+// NewFreshTaskT returns a FreshTask for any Tasker where transform(TaskInfo.Src) is the Target of t
+func NewFreshTaskT(transform Transformer, t Tasker, prereqs ...string) *FreshTask {
+	return &FreshTask{Task: t, Transform: transform, Prereqs: prereqs}
+}
+
+// Target returns the Target the task will produce for src
+func (ft *FreshTask) Target(src string) string {
+	return ft.Transform(src)
+}
+
+// Run will run Task if the Target is out of date
+func (ft *FreshTask) Run(info *TaskInfo) (err error) {
+	target := ft.Transform(info.Src)
+	deps, err := ft.deps(info.Src)
+	if err != nil {
+		return
+	}
+	sum := ""
+	if ft.Hash {
+		if sum, err = depSum(deps); err != nil {
+			return
+		}
+	}
+	if target != info.Src && ft.fresh(target, deps, sum) {
+		ft.record(target, sum)
+		info.Target = target
+		info.Buf.Reset()
+		if info.Verbose {
+			fmt.Fprintf(info.Tout, "<< %v is up to date\n", target)
+		}
+		return
+	}
+	if err = ft.Task.Run(info); err == nil {
+		ft.record(target, sum)
+	}
+	return
+}
+
+func (ft *FreshTask) record(target, sum string) {
+	if !ft.Hash {
+		return
+	}
+	ft.mu.Lock()
+	if ft.sums == nil {
+		ft.sums = make(map[string]string)
+	}
+	ft.sums[target] = sum
+	ft.mu.Unlock()
+}
+
+// deps returns the files the Target depends on
+func (ft *FreshTask) deps(src string) ([]string, error) {
+	deps := []string{src}
+	for _, g := range ft.Prereqs {
+		m, err := filepath.Glob(g)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(m)
+		deps = append(deps, m...)
+	}
+	return deps, nil
+}
+
+func (ft *FreshTask) fresh(target string, deps []string, sum string) bool {
+	tfi, err := os.Stat(target)
+	if err != nil {
+		return false
+	}
+	if ft.Hash {
+		ft.mu.Lock()
+		last, ok := ft.sums[target]
+		ft.mu.Unlock()
+		if ok {
+			return last == sum
+		}
+		// nothing recorded yet, fall back to modification times
+	}
+	for _, d := range deps {
+		fi, err := os.Stat(d)
+		if err != nil || fi.ModTime().After(tfi.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
+
+// depSum returns a hash of the names and contents of files
+func depSum(deps []string) (string, error) {
+	h := sha256.New()
+	for _, d := range deps {
+		if err := hashFile(h, d); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
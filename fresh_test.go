@@ -0,0 +1,140 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFreshTask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "a.txt")
+	inc := filepath.Join(dir, "a.inc")
+	ioutil.WriteFile(src, []byte("a"), 0644)
+	ioutil.WriteFile(inc, []byte("inc"), 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(src, old, old)
+	os.Chtimes(inc, old, old)
+
+	runs := 0
+	build := NewTask(ExtTransformer("out"), func(i *TaskInfo) error {
+		runs++
+		return ioutil.WriteFile(i.Target, []byte("out"), 0644)
+	})
+
+	for _, hash := range []bool{false, true} {
+		runs = 0
+		os.Remove(filepath.Join(dir, "a.out"))
+		ft := NewFreshTask(build, filepath.Join(dir, "*.inc"))
+		ft.Hash = hash
+
+		run := func(expect int) {
+			info := TaskInfo{Src: src, Tout: ioutil.Discard, Terr: ioutil.Discard}
+			if err := ft.Run(&info); err != nil {
+				t.Fatal(err)
+			}
+			if runs != expect {
+				t.Errorf("Hash %v: expected %v runs got %v", hash, expect, runs)
+			}
+			if info.Target != filepath.Join(dir, "a.out") {
+				t.Errorf("Expected Target a.out got %v", info.Target)
+			}
+		}
+
+		run(1)
+		run(1)
+
+		// a newer prerequisite makes the target stale unless the contents are the same
+		now := time.Now().Add(time.Hour)
+		os.Chtimes(inc, now, now)
+		if hash {
+			run(1)
+			ioutil.WriteFile(inc, []byte("changed"), 0644)
+		}
+		run(2)
+		os.Chtimes(inc, old, old)
+		run(2)
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.Mkdir(filepath.Join(dir, ".hidden"), 0755)
+	for _, f := range []string{"a.txt", "b.go", "sub/c.txt", ".hidden/d.txt"} {
+		ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644)
+	}
+
+	var got []string
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Wout, p.Werr = ioutil.Discard, ioutil.Discard
+	wf := NewWorkflow(NewTask(Identity, func(i *TaskInfo) error {
+		got = append(got, filepath.Base(i.Src))
+		return nil
+	}))
+	wf.WatchPattern(`\.txt$`)
+	p.Add(wf)
+
+	res, err := p.RunOnce(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK() || res.Runs != 2 || res.ExitCode() != 0 {
+		t.Errorf("Expected 2 successful runs got %v %v", res.Runs, res.Failed)
+	}
+	if len(got) != 2 || got[0] != "a.txt" || got[1] != "c.txt" {
+		t.Errorf("Expected [a.txt c.txt] got %v", got)
+	}
+
+	fail := NewWorkflow(testTask{showError: true})
+	fail.Concurrent = true
+	fail.WatchPattern(`\.go$`)
+	p.Add(fail)
+	res, err = p.RunOnce(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK() || res.Runs != 3 || len(res.Failed) != 1 || res.ExitCode() != 1 {
+		t.Errorf("Expected RunOnce to report the failed workflow got %v %v", res.Runs, res.Failed)
+	}
+
+	if _, err = p.RunOnce(filepath.Join(dir, "bogus")); err == nil {
+		t.Errorf("Expected error for bogus path")
+	}
+
+	// a non recursive watch only lists its own directory
+	p = NewPipeline("Test Pipeline", Silent)
+	p.Wout, p.Werr = ioutil.Discard, ioutil.Discard
+	p.Add(wf)
+	p.Watch(dir)
+	got = nil
+	if _, err = p.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "a.txt" {
+		t.Errorf("Expected [a.txt] got %v", got)
+	}
+	p.Watches = nil
+	p.WatchRecursive(dir, IncludeHidden)
+	got = nil
+	if _, err = p.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Errorf("Expected a.txt, c.txt and d.txt got %v", got)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package gotask
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/dshills/goauto"
+)
+
+// An EmbedTask generates a Go source file with the contents of the files under an assets directory
+// so they can be compiled into a single binary
+// The generated file declares an Asset type and a map of the files by slash separated path
+//
+//	a := assets.Assets["css/app.css"]
+//	w.Header().Set("Content-Type", a.MIME)
+//	http.ServeContent(w, r, a.Name, a.ModTime, bytes.NewReader(a.Bytes()))
+//
+// With Gzip files that compress are stored compressed and can be sent as is with Content-Encoding: gzip
+// The Go file is only written when its contents change, a file rewritten with the same content
+// keeps its previous ModTime so a build step that touches every asset does not cause a rebuild
+// The previous ModTimes are read back from the Go file so this also holds after a restart
+type EmbedTask struct {
+	Root    string // Directory of the assets, hidden files and directories are skipped
+	Output  string // Go file to generate
+	Package string // Package name, defaults to the name of the directory of Output
+	Var     string // Name of the map variable, defaults to Assets
+	Gzip    bool   // Store files gzip compressed when it makes them smaller
+	mu      sync.Mutex
+	sums    map[string]embedSum
+}
+
+// embedSum is the content hash and modification time of an asset when it was last generated
+type embedSum struct {
+	sum [sha256.Size]byte
+	mod time.Time
+}
+
+// NewEmbedTask returns an EmbedTask that embeds the files under root in output
+// goauto.TaskInfo.Target is set to output
+func NewEmbedTask(root, output string) *EmbedTask {
+	return &EmbedTask{Root: root, Output: output}
+}
+
+type embedAsset struct {
+	Name    string
+	Size    int
+	ModTime int64
+	MIME    string
+	Gzip    bool
+	Data    string
+}
+
+var embedTmpl = template.Must(template.New("embed").Parse(`// Code generated by goauto EmbedTask; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"time"
+)
+
+// An Asset is an embedded file
+type Asset struct {
+	Name    string // Slash separated path relative to the assets directory
+	Size    int64  // Uncompressed size
+	ModTime time.Time
+	MIME    string
+	Gzip    bool   // Data is gzip compressed
+	Data    string
+}
+
+// Bytes returns the uncompressed content of the Asset
+func (a *Asset) Bytes() []byte {
+	if !a.Gzip {
+		return []byte(a.Data)
+	}
+	r, err := gzip.NewReader(bytes.NewReader([]byte(a.Data)))
+	if err != nil {
+		panic(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// {{.Var}} are the embedded files by Name
+var {{.Var}} = map[string]*Asset{
+{{range .Assets}}	{{printf "%q" .Name}}: {Name: {{printf "%q" .Name}}, Size: {{.Size}}, ModTime: time.Unix({{.ModTime}}, 0), MIME: {{printf "%q" .MIME}}, Gzip: {{.Gzip}}, Data: {{.Data}}},
+{{end}}}
+`))
+
+// Run will regenerate the Go file if any of the assets changed
+func (et *EmbedTask) Run(info *goauto.TaskInfo) (err error) {
+	t0 := time.Now()
+	info.Target = et.Output
+	info.Buf.Reset()
+	et.mu.Lock()
+	defer et.mu.Unlock()
+
+	out, err := filepath.Abs(et.Output)
+	if err != nil {
+		return
+	}
+	pkg, name := et.Package, et.Var
+	if pkg == "" {
+		pkg = filepath.Base(filepath.Dir(out))
+	}
+	if name == "" {
+		name = "Assets"
+	}
+
+	if et.sums == nil {
+		et.sums = loadSums(et.Output)
+	}
+	sums := make(map[string]embedSum)
+	var assets []embedAsset
+	err = filepath.Walk(et.Root, func(fpath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fpath != et.Root && goauto.IsHidden(fi.Name()) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		if abs, err := filepath.Abs(fpath); err != nil || abs == out {
+			return err
+		}
+		b, err := ioutil.ReadFile(fpath)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(et.Root, fpath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		s := embedSum{sum: sha256.Sum256(b), mod: fi.ModTime()}
+		if prev, ok := et.sums[rel]; ok && prev.sum == s.sum {
+			s.mod = prev.mod
+		}
+		sums[rel] = s
+
+		a := embedAsset{Name: rel, Size: len(b), ModTime: s.mod.Unix(), MIME: mime.TypeByExtension(filepath.Ext(fpath))}
+		if a.MIME == "" {
+			a.MIME = http.DetectContentType(b)
+		}
+		if et.Gzip {
+			var z bytes.Buffer
+			w := gzip.NewWriter(&z)
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+			if z.Len() < len(b) {
+				a.Gzip = true
+				b = z.Bytes()
+			}
+		}
+		a.Data = strconv.Quote(string(b))
+		assets = append(assets, a)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	var src bytes.Buffer
+	err = embedTmpl.Execute(&src, struct {
+		Package, Var string
+		Assets       []embedAsset
+	}{pkg, name, assets})
+	if err != nil {
+		return
+	}
+	b, err := format.Source(src.Bytes())
+	if err != nil {
+		return
+	}
+	if old, err := ioutil.ReadFile(et.Output); err == nil && bytes.Equal(old, b) {
+		et.sums = sums
+		if info.Verbose {
+			fmt.Fprintf(info.Tout, "<< %v unchanged\n", et.Output)
+		}
+		return nil
+	}
+	if err = ioutil.WriteFile(et.Output, b, 0644); err != nil {
+		return
+	}
+	et.sums = sums
+	if info.Verbose {
+		fmt.Fprintf(info.Tout, "<< Go Embed %v files %v %v\n", len(assets), et.Output, time.Now().Sub(t0))
+	}
+	return
+}
+
+var embedEntry = regexp.MustCompile(`\{Name: ("(?:[^"\\]|\\.)*"), Size: \d+, ModTime: time\.Unix\((-?\d+), 0\), MIME: "(?:[^"\\]|\\.)*", Gzip: (true|false), Data: ("(?:[^"\\]|\\.)*")\}`)
+
+// loadSums returns the content hash and modification time of the assets in a previously generated file
+// Entries that can not be read are left out
+func loadSums(file string) map[string]embedSum {
+	sums := make(map[string]embedSum)
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return sums
+	}
+	for _, l := range strings.Split(string(b), "\n") {
+		m := embedEntry.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		name, err1 := strconv.Unquote(m[1])
+		mod, err2 := strconv.ParseInt(m[2], 10, 64)
+		data, err3 := strconv.Unquote(m[4])
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		content := []byte(data)
+		if m[3] == "true" {
+			r, err := gzip.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			if content, err = ioutil.ReadAll(r); err != nil {
+				continue
+			}
+		}
+		sums[name] = embedSum{sum: sha256.Sum256(content), mod: time.Unix(mod, 0)}
+	}
+	return sums
+}
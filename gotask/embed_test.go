@@ -0,0 +1,109 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package gotask
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/goauto"
+)
+
+func TestEmbed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-embed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "assets")
+	files := map[string]string{
+		"css/app.css":  strings.Repeat("body { color: red; }\n", 20),
+		"js/app.js":    "x()",
+		".hidden/a.js": "hidden",
+		"img/.keep":    "",
+	}
+	for n, c := range files {
+		f := filepath.Join(root, filepath.FromSlash(n))
+		if err = os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(f, []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(root, "assets.go")
+	et := NewEmbedTask(root, out)
+	et.Gzip = true
+	info := &goauto.TaskInfo{Src: filepath.Join(root, "js", "app.js"), Tout: ioutil.Discard, Terr: ioutil.Discard}
+	if err = et.Run(info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Target != out {
+		t.Errorf("Expected Target %v got %v", out, info.Target)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(b)
+	if _, err = parser.ParseFile(token.NewFileSet(), out, b, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package assets",
+		`{Name: "js/app.js", Size: 3,`,
+		`MIME: "` + mime.TypeByExtension(".js") + `", Gzip: false, Data: "x()"}`,
+		`MIME: "` + mime.TypeByExtension(".css") + `", Gzip: true, Data: "\x1f\x8b`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Expected %q in\n%v", want, src)
+		}
+	}
+	if strings.Contains(src, "hidden") || strings.Contains(src, "assets.go") {
+		t.Errorf("Expected hidden files and the output to be skipped\n%v", src)
+	}
+
+	// rewriting an asset with the same content does not change the output
+	fi, _ := os.Stat(out)
+	old := fi.ModTime().Add(-time.Hour)
+	os.Chtimes(out, old, old)
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(root, "js", "app.js"), later, later)
+	if err = et.Run(info); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ = os.Stat(out); !fi.ModTime().Equal(old) {
+		t.Error("Expected the output not to be rewritten")
+	}
+
+	// the ModTimes are read back from the output after a restart
+	et = NewEmbedTask(root, out)
+	et.Gzip = true
+	later = later.Add(time.Hour)
+	os.Chtimes(filepath.Join(root, "js", "app.js"), later, later)
+	os.Chtimes(filepath.Join(root, "css", "app.css"), later, later)
+	if err = et.Run(info); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ = os.Stat(out); !fi.ModTime().Equal(old) {
+		t.Error("Expected the output not to be rewritten after a restart")
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(root, "js", "app.js"), []byte("y()"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = et.Run(info); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ = ioutil.ReadFile(out); !strings.Contains(string(b), `Data: "y()"`) {
+		t.Errorf("Expected the output to be regenerated\n%s", b)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const keyHelp = `Keys:
+  r  rerun the last Workflow
+  a  rerun all Workflows for every watched file
+  l  list Workflows and their status
+  p  pause or resume watching
+  v  toggle verbose output
+  c  clear the screen
+  q  quit
+  h  show this help
+`
+
+// startInteractive reads key commands from the terminal
+// returns a function that restores the terminal
+func (p *Pipeline) startInteractive() (restore func()) {
+	// Start also handles signals when Interactive so Ctrl-C restores the terminal
+	restore, err := cbreak(os.Stdin)
+	if err != nil {
+		restore = func() {}
+		fmt.Fprintln(p.Wout, "> Not a terminal, press Enter after each key")
+	}
+
+	go p.interact(os.Stdin)
+	fmt.Fprintln(p.Wout, "> Press h for help")
+	return restore
+}
+
+// interact runs the command for each key read from in until q is pressed
+func (p *Pipeline) interact(in io.Reader) {
+	r := bufio.NewReader(in)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case 'r':
+			p.do(p.rerunLast)
+		case 'a':
+			p.do(p.rerunAll)
+		case 'l':
+			p.do(func() { p.list(p.Wout) })
+		case 'p':
+			p.do(p.togglePause)
+		case 'v':
+			p.do(func() {
+				p.Verbose = !p.Verbose
+				fmt.Fprintln(p.Wout, "> Verbose", p.Verbose)
+			})
+		case 'c':
+			fmt.Fprint(p.Wout, "\033[H\033[2J")
+		case 'q':
+			p.Stop()
+			return
+		case 'h', '?':
+			fmt.Fprint(p.Wout, keyHelp)
+		}
+	}
+}
+
+func (p *Pipeline) rerunLast() {
+	if p.lastWf == nil {
+		fmt.Fprintln(p.Wout, "> Nothing has run yet")
+		return
+	}
+	e := p.lastEvent
+	p.lastWf.Run(&TaskInfo{Src: e.Path, Tout: p.Wout, Terr: p.Werr, Verbose: p.Verbose, pipeline: p})
+}
+
+func (p *Pipeline) rerunAll() {
+	for _, e := range p.expand(ESlice{&Event{Op: Write}}) {
+		p.run(e, nil)
+	}
+}
+
+func (p *Pipeline) togglePause() {
+	if !p.Paused() {
+		p.Pause(BufferEvents)
+		fmt.Fprintln(p.Wout, "> Paused, changes will run when resumed")
+		return
+	}
+	fmt.Fprintln(p.Wout, "> Resumed")
+	p.runHeld(p.unpause()) // already on the Workflow goroutine
+}
+
+// list writes the status of each Workflow to w
+func (p *Pipeline) list(w io.Writer) {
+	for i, wf := range p.Workflows {
+		fmt.Fprintf(w, "%3d %-20v ", i+1, wfName(wf))
+		cw, ok := wf.(*Workflow)
+		if !ok {
+			fmt.Fprintln(w)
+			continue
+		}
+		s := cw.Status()
+		switch {
+		case s.Start.IsZero():
+			fmt.Fprintln(w, "not run")
+		case s.Running:
+			fmt.Fprintf(w, "running for %v since %v\n", s.Src, s.Start.Format(time.Kitchen))
+		case s.Err != nil:
+			fmt.Fprintf(w, "FAILED for %v at %v: %v\n", s.Src, s.Start.Format(time.Kitchen), s.Err)
+		default:
+			fmt.Fprintf(w, "ok for %v at %v in %v\n", s.Src, s.Start.Format(time.Kitchen), s.Duration)
+		}
+	}
+}
+
+// cbreak puts the terminal f in cbreak mode so keys are read as they are pressed without echo
+// Ctrl-C still sends an interrupt
+// returns a function that restores the terminal
+func cbreak(f *os.File) (restore func(), err error) {
+	state, err := stty(f, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = stty(f, "-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(f, strings.TrimSpace(state)) }, nil
+}
+
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,50 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestInteract(t *testing.T) {
+	var out bytes.Buffer
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Wout, p.Werr = &out, ioutil.Discard
+
+	runs := 0
+	wf := NewWorkflow(NewTask(Identity, func(*TaskInfo) error {
+		runs++
+		return nil
+	}))
+	wf.Name = "count"
+	wf.WatchPattern(`\.go$`)
+	p.Add(wf, NewWorkflow())
+
+	p.control = make(chan func())
+	p.done = make(chan struct{})
+	in := p.queryWorkflow()
+
+	p.interact(strings.NewReader("r"))
+	in <- &Event{Path: "a.go", Op: Write}
+	p.interact(strings.NewReader("pv"))
+	in <- &Event{Path: "b.go", Op: Write} // paused
+	p.interact(strings.NewReader("rl"))
+	p.do(func() {}) // wait for the list
+	close(in)
+
+	if runs != 2 {
+		t.Errorf("Expected 2 runs got %v", runs)
+	}
+	if wf.Status().Src != "a.go" || wf.Status().Err != nil {
+		t.Errorf("Expected successful run for a.go got %v", wf.Status())
+	}
+	for _, e := range []string{"Nothing has run", "Paused", "Verbose true", "count", "ok for a.go", "not run"} {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("Expected %q in output\n%v", e, out.String())
+		}
+	}
+}
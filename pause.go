@@ -0,0 +1,161 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import "fmt"
+
+// A PausePolicy controls what happens to Events while a Pipeline is paused or a Workflow is disabled
+type PausePolicy uint8
+
+// Pause policies
+const (
+	BufferEvents PausePolicy = iota // Run each affected Workflow once for all of the changed files on resume
+	DropEvents                      // Ignore Events while paused
+)
+
+// Pause stops the Pipeline from running Workflows without removing the watches
+// Use BufferEvents to catch up on Resume, or DropEvents to ignore changes made while paused
+func (p *Pipeline) Pause(policy PausePolicy) {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	p.paused = true
+	p.policy = policy
+	if p.Verbose {
+		fmt.Fprintln(p.Wout, "> Pipeline", p.Name, "paused")
+	}
+}
+
+// Paused reports whether the Pipeline is paused
+func (p *Pipeline) Paused() bool {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	return p.paused
+}
+
+// Resume starts running Workflows again
+// Each Workflow matching Events buffered while paused is run once
+// with TaskInfo.Src set to the last changed file and TaskInfo.Collect holding all of them
+// On a running Pipeline the buffered runs are queued after the current Event and Resume does not
+// wait for them, so it is safe to call from a Task
+func (p *Pipeline) Resume() {
+	held := p.unpause()
+	if p.Verbose {
+		fmt.Fprintln(p.Wout, "> Pipeline", p.Name, "resumed with", len(held), "changes")
+	}
+	if len(held) == 0 {
+		return
+	}
+	p.later(func() { p.runHeld(held) })
+}
+
+// unpause returns the Events held while paused
+func (p *Pipeline) unpause() ESlice {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	held := p.held
+	p.paused, p.held = false, nil
+	return held
+}
+
+// hold buffers or drops an Event if the Pipeline is paused
+// returns false if the Pipeline is not paused
+func (p *Pipeline) hold(e *Event) bool {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if !p.paused {
+		return false
+	}
+	if p.policy == BufferEvents {
+		p.held = append(p.held, e)
+	}
+	return true
+}
+
+// runHeld runs each Workflow matching the held Events once
+func (p *Pipeline) runHeld(held ESlice) {
+	for _, wf := range p.Workflows {
+		var paths []string
+		seen := make(map[string]bool)
+		for _, e := range held {
+			if !seen[e.Path] && wf.Match(e.Path, e.Op) {
+				seen[e.Path] = true
+				paths = append(paths, e.Path)
+			}
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		p.lastWf, p.lastEvent = wf, &Event{Path: paths[len(paths)-1], Op: Write}
+		wf.Run(&TaskInfo{Src: paths[len(paths)-1], Collect: paths, Tout: p.Wout, Terr: p.Werr, Verbose: p.Verbose, pipeline: p})
+	}
+}
+
+// Disable stops the Workflow from running without removing it from the Pipeline
+// Use BufferEvents to run once for all of the files changed while disabled on Enable,
+// or DropEvents to ignore them
+func (wf *Workflow) Disable(policy PausePolicy) {
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	wf.disabled = true
+	wf.policy = policy
+}
+
+// Enabled reports whether the Workflow is enabled
+func (wf *Workflow) Enabled() bool {
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	return !wf.disabled
+}
+
+// Enable lets a disabled Workflow run again
+// If files changed while disabled the Workflow is run once
+// with TaskInfo.Src set to the last changed file and TaskInfo.Collect holding all of them
+// On a running Pipeline the run is queued after the current Event and Enable does not
+// wait for it, so it is safe to call from a Task
+func (wf *Workflow) Enable() {
+	wf.mu.Lock()
+	info := wf.held
+	wf.disabled, wf.held = false, nil
+	wf.mu.Unlock()
+	if info == nil {
+		return
+	}
+	info.pipeline.later(func() { wf.Run(info) })
+}
+
+// hold buffers or drops a run if the Workflow is disabled
+// returns false if the Workflow is enabled
+func (wf *Workflow) hold(info *TaskInfo) bool {
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	if !wf.disabled {
+		return false
+	}
+	if wf.policy == DropEvents {
+		return true
+	}
+	if wf.held == nil {
+		wf.held = &TaskInfo{Tout: info.Tout, Terr: info.Terr, Verbose: info.Verbose, pipeline: info.pipeline}
+	}
+	files := info.Collect
+	if len(files) == 0 {
+		files = []string{info.Src}
+	}
+	for _, f := range files {
+		if !contains(wf.held.Collect, f) {
+			wf.held.Collect = append(wf.held.Collect, f)
+		}
+	}
+	wf.held.Src = info.Src
+	return true
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPipelinePause(t *testing.T) {
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Wout, p.Werr = ioutil.Discard, ioutil.Discard
+
+	var got [][]string
+	wf := NewWorkflow(NewTask(Identity, func(i *TaskInfo) error {
+		got = append(got, append([]string{i.Src}, i.Collect...))
+		return nil
+	}))
+	wf.WatchPattern(`\.go$`)
+	p.Add(wf)
+
+	p.Pause(BufferEvents)
+	for _, f := range []string{"a.go", "b.go", "a.go", "c.txt"} {
+		if !p.hold(&Event{Path: f, Op: Write}) {
+			t.Errorf("Expected %v to be held", f)
+		}
+	}
+	if !p.Paused() || len(got) != 0 {
+		t.Errorf("Expected paused Pipeline not to run")
+	}
+	p.Resume()
+	if p.Paused() {
+		t.Errorf("Expected Pipeline to be resumed")
+	}
+	if len(got) != 1 || len(got[0]) != 3 || got[0][0] != "b.go" || got[0][1] != "a.go" {
+		t.Errorf("Expected one run for b.go with [a.go b.go] got %v", got)
+	}
+
+	got = nil
+	p.Pause(DropEvents)
+	p.hold(&Event{Path: "a.go", Op: Write})
+	p.Resume()
+	if p.hold(&Event{Path: "a.go", Op: Write}) || len(got) != 0 {
+		t.Errorf("Expected dropped Events not to run got %v", got)
+	}
+}
+
+func TestWorkflowDisable(t *testing.T) {
+	runs := 0
+	var collect []string
+	wf := NewWorkflow(NewTask(Identity, func(i *TaskInfo) error {
+		runs++
+		collect = i.Collect
+		return nil
+	}))
+
+	wf.Disable(BufferEvents)
+	if wf.Enabled() {
+		t.Errorf("Expected Workflow to be disabled")
+	}
+	wf.Run(&TaskInfo{Src: "a.go", Tout: ioutil.Discard, Terr: ioutil.Discard})
+	wf.Run(&TaskInfo{Src: "b.go", Tout: ioutil.Discard, Terr: ioutil.Discard})
+	if runs != 0 {
+		t.Errorf("Expected disabled Workflow not to run")
+	}
+	wf.Enable()
+	if runs != 1 || len(collect) != 2 || collect[1] != "b.go" {
+		t.Errorf("Expected one run for [a.go b.go] got %v %v", runs, collect)
+	}
+
+	wf.Disable(DropEvents)
+	wf.Run(&TaskInfo{Src: "a.go", Tout: ioutil.Discard, Terr: ioutil.Discard})
+	wf.Enable()
+	wf.Enable()
+	if runs != 1 {
+		t.Errorf("Expected dropped run got %v runs", runs)
+	}
+}
+
+func TestResumeFromTask(t *testing.T) {
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Wout, p.Werr = ioutil.Discard, ioutil.Discard
+
+	ran := make(chan string, 2)
+	muted := NewWorkflow(NewTask(Identity, func(i *TaskInfo) error {
+		ran <- i.Src
+		return nil
+	}))
+	muted.WatchPattern(`\.txt$`)
+	muted.Disable(BufferEvents)
+	// Resume and Enable are called on the Workflow goroutine
+	wf := NewWorkflow(NewTask(Identity, func(i *TaskInfo) error {
+		p.Pause(BufferEvents)
+		p.hold(&Event{Path: "b.txt", Op: Write})
+		p.Resume()
+		muted.Enable()
+		return nil
+	}))
+	wf.WatchPattern(`\.go$`)
+	p.Add(muted, wf)
+
+	p.control = make(chan func())
+	p.done = make(chan struct{})
+	in := p.queryWorkflow()
+	in <- &Event{Path: "a.txt", Op: Write}
+	in <- &Event{Path: "a.go", Op: Write}
+	got := make(map[string]bool)
+	for len(got) < 2 {
+		select {
+		case f := <-ran:
+			got[f] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Expected Resume and Enable from a Task to run the held Workflows got %v", got)
+		}
+	}
+	if !got["a.txt"] || !got["b.txt"] {
+		t.Errorf("Expected runs for a.txt and b.txt got %v", got)
+	}
+	close(in)
+	p.dispatching.Wait()
+	close(p.done)
+}
+
+func TestPipelinePauseRunAtStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Wout, p.Werr = ioutil.Discard, ioutil.Discard
+	p.Watch(dir)
+	ran := make(chan string, 1)
+	wf := NewWorkflow(NewTask(Identity, func(i *TaskInfo) error {
+		ran <- i.Src
+		return nil
+	}))
+	wf.WatchPattern(`\.go$`)
+	p.Add(wf)
+
+	p.control, p.done = make(chan func()), make(chan struct{})
+	qwc := p.queryWorkflow()
+	defer close(p.done)
+	defer close(qwc)
+
+	p.Pause(BufferEvents)
+	p.initialRun()
+	p.do(func() {}) // the initial run is done once the Workflow goroutine takes the next func
+	select {
+	case src := <-ran:
+		t.Errorf("Expected paused Pipeline not to run %v", src)
+	default:
+	}
+
+	p.Resume()
+	select {
+	case src := <-ran:
+		if src != filepath.Join(dir, "a.go") {
+			t.Errorf("Expected run for a.go got %v", src)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the initial run to be buffered until Resume")
+	}
+}
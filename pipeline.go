@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -33,20 +34,44 @@ const (
 
 // A Pipeline watches one or more directories for changes
 type Pipeline struct {
-	Name       string
-	Watches    []string
-	Wout, Werr io.Writer
-	Workflows  []Workflower
-	Verbose    bool
-	OSX        bool
-	watcher    Watcher
-	recDirs    map[string]bool
-	events     <-chan ESlice
+	Name        string
+	Watches     []string
+	Wout, Werr  io.Writer
+	Workflows   []Workflower
+	Triggers    []Trigger
+	Verbose     bool
+	OSX         bool
+	MaxWorkers  int           // Maximum number of Concurrent Workflows running at once, 0 is unlimited
+	RunAtStart  bool          // Run the matching Workflows for the existing files when the Pipeline starts (See RunOnce)
+	Interactive bool          // Read single key commands from the terminal while running
+	DryRun      bool          // Explain which Workflows match each Event instead of running them
+	Signals     bool          // Stop gracefully on SIGINT or SIGTERM, set by NewPipeline
+	GracePeriod time.Duration // Time to wait for running Workflows when stopping, defaults to 10s
+	watcher     Watcher
+	recDirs     map[string]bool
+	events      <-chan ESlice
+	pool        *workerPool
+	poolMu      sync.Mutex
+	sems        map[string]chan struct{}
+	semMu       sync.Mutex
+	control     chan func() // run by the Workflow goroutine between Events
+	done        chan struct{}
+	pauseMu     sync.Mutex
+	paused      bool
+	policy      PausePolicy
+	held        ESlice
+	lastWf      Workflower
+	lastEvent   *Event
+	quit        chan struct{} // closed by Stop
+	quitOnce    sync.Once
+	running     sync.WaitGroup // running Workflows
+	dispatching sync.WaitGroup // the Workflow goroutine, it starts the running Workflows
+	services    []Service
 }
 
 // NewPipeline returns a basic Pipeline with a dir to watch, output and error writers and a workflow
 func NewPipeline(name string, verbose bool) *Pipeline {
-	p := Pipeline{Name: name, Wout: os.Stdout, Werr: os.Stderr, Verbose: verbose}
+	p := Pipeline{Name: name, Wout: os.Stdout, Werr: os.Stderr, Verbose: verbose, Signals: true}
 	return &p
 }
 
@@ -114,9 +139,17 @@ func (p *Pipeline) Add(ws ...Workflower) {
 	}
 }
 
+// AddTrigger adds one or more Triggers as sources of Events in addition to the file system watches
+func (p *Pipeline) AddTrigger(ts ...Trigger) {
+	p.Triggers = append(p.Triggers, ts...)
+}
+
 // Start begins watching for changes to files in the Watches directories
 // Detected file changes will be compared with workflow regexp and if match will run the workflow tasks
-func (p *Pipeline) Start() {
+// Start blocks until the Pipeline is stopped by Stop or a signal (See Signals)
+// It then waits up to GracePeriod for running Workflows and stops any Stopper tasks such as RestartTask
+// An error is returned if the Pipeline could not start or did not shut down cleanly
+func (p *Pipeline) Start() error {
 	if p.watcher == nil {
 		if p.OSX {
 			p.watcher = NewWatchOSX()
@@ -128,15 +161,7 @@ func (p *Pipeline) Start() {
 		}
 	}
 
-	if p.Wout == nil {
-		p.Wout = os.Stdout
-	}
-	if p.Werr == nil {
-		p.Werr = os.Stderr
-	}
-	if p.Name == "" {
-		p.Name = "<UNNAMED>"
-	}
+	p.defaults()
 
 	if len(p.Watches) < 1 {
 		fmt.Fprintln(p.Werr, "Pipeline", p.Name, "is not watching anything")
@@ -146,19 +171,59 @@ func (p *Pipeline) Start() {
 		fmt.Fprintln(p.Werr, "Pipeline", p.Name, "has no Workflows")
 	}
 
+	if err := p.startServices(); err != nil {
+		fmt.Fprintln(p.Werr, err)
+		return err
+	}
+
 	// setup the com channels
+	p.control = make(chan func())
+	p.done = make(chan struct{})
+	p.quit = make(chan struct{})
+	p.quitOnce = sync.Once{}
+	defer close(p.done)
 	qdc := p.queryRecDir()
 	qwc := p.queryWorkflow()
 
-	var err error
-	p.events, err = p.watcher.Start(batchTick, p.Watches)
+	events, err := p.watcher.Start(batchTick, p.Watches)
 	if err != nil {
 		fmt.Fprintln(p.Werr, err)
-		return
+		close(qdc)
+		close(qwc)
+		p.stopServices(p.services)
+		return err
+	}
+	p.events = p.startTriggers(events)
+
+	if p.Signals || p.Interactive {
+		defer p.handleSignals()()
+	}
+	if p.Interactive {
+		defer p.startInteractive()()
+	}
+
+	// changes made during the initial run are picked up by the watcher
+	// and handled once it finishes
+	if p.RunAtStart {
+		p.initialRun()
 	}
 
 	// block
 	p.distributeEvents(qdc, qwc)
+	return p.shutdown()
+}
+
+// defaults sets the output, error writers and name if not set
+func (p *Pipeline) defaults() {
+	if p.Wout == nil {
+		p.Wout = os.Stdout
+	}
+	if p.Werr == nil {
+		p.Werr = os.Stderr
+	}
+	if p.Name == "" {
+		p.Name = "<UNNAMED>"
+	}
 }
 
 // distributeEvents sends batched events to a list of write channels
@@ -178,36 +243,84 @@ func (p *Pipeline) distributeEvents(cs ...chan<- *Event) {
 			}
 			for _, e := range d {
 				for _, c := range cs {
-					c <- e
+					select {
+					case c <- e:
+					case <-p.quit:
+						return
+					}
 				}
 			}
+		case <-p.quit:
+			return
 		}
 	}
 }
 
 // queryWorkflow checks for file match for each workflow and if matches executes the workflow tasks
-// returns a write channel that the caller should close
+// returns a write channel that the caller should close, the goroutine exits once it is drained
 func (p *Pipeline) queryWorkflow() chan<- *Event {
 	in := make(chan *Event)
 
+	p.dispatching.Add(1)
 	go func() {
+		defer p.dispatching.Done()
 		for {
 			select {
 			case e := <-in:
 				if e == nil {
 					return
 				}
-				for _, wf := range p.Workflows {
-					if wf.Match(e.Path, e.Op) {
-						wf.Run(&TaskInfo{Src: e.Path, Tout: p.Wout, Terr: p.Werr, Verbose: p.Verbose})
-					}
+				if !p.hold(e) {
+					p.run(e, nil)
 				}
+			case f := <-p.control:
+				f()
 			}
 		}
 	}()
 	return in
 }
 
+// run executes each Workflow matching the Event
+// ps tracks the runs for RunOnce and may be nil
+func (p *Pipeline) run(e *Event, ps *pass) {
+	if p.DryRun {
+		p.explain(e)
+		return
+	}
+	for _, wf := range p.Workflows {
+		if wf.Match(e.Path, e.Op) {
+			p.lastWf, p.lastEvent = wf, e
+			wf.Run(&TaskInfo{Src: e.Path, Tout: p.Wout, Terr: p.Werr, Verbose: p.Verbose, pipeline: p, pass: ps})
+		}
+	}
+}
+
+// do runs f on the Workflow goroutine so it does not race with running Workflows
+// returns false if the Pipeline is not running
+func (p *Pipeline) do(f func()) bool {
+	if p == nil || p.control == nil {
+		return false
+	}
+	select {
+	case p.control <- f:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+// later queues f to run on the Workflow goroutine without waiting for it
+// so it can be called from a Task running on that goroutine
+// f is run at once if the Pipeline was never started and dropped if it stops first
+func (p *Pipeline) later(f func()) {
+	if p == nil || p.control == nil {
+		f()
+		return
+	}
+	go p.do(f)
+}
+
 // matchNewRec checks if an event is adding or renaming a directory in a recursive watch
 // reruns WatchRecursive if it is
 func (p *Pipeline) matchNewRec(e Event) {
@@ -250,11 +363,89 @@ func (p *Pipeline) queryRecDir() chan<- *Event {
 	return in
 }
 
+// startTriggers merges the Trigger Events with the watcher Events
+// Triggers that fail to start are reported and skipped
+func (p *Pipeline) startTriggers(events <-chan ESlice) <-chan ESlice {
+	if len(p.Triggers) == 0 {
+		return events
+	}
+	merged := make(chan ESlice)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for d := range events {
+			select {
+			case merged <- d:
+			case <-p.quit:
+				return
+			}
+		}
+		select {
+		case merged <- nil: // the watcher stopped, stop distributing
+		case <-p.quit:
+		}
+	}()
+	for _, t := range p.Triggers {
+		if r, ok := t.(interface {
+			setErr(io.Writer)
+		}); ok {
+			r.setErr(p.Werr)
+		}
+		c, err := t.Start()
+		if err != nil {
+			fmt.Fprintln(p.Werr, err)
+			continue
+		}
+		go func(c <-chan ESlice) {
+			for d := range c {
+				es := p.expand(d)
+				if len(es) == 0 {
+					continue // an empty ESlice stops distributeEvents
+				}
+				select {
+				case merged <- es:
+				case <-done:
+					return
+				}
+			}
+		}(c)
+	}
+	return merged
+}
+
+// expand replaces Events with a blank Path with an Event for every watched file
+func (p *Pipeline) expand(d ESlice) ESlice {
+	es := make(ESlice, 0, len(d))
+	for _, e := range d {
+		if e.Path != "" {
+			es = append(es, e)
+			continue
+		}
+		files, err := p.watchedFiles()
+		if err != nil {
+			fmt.Fprintln(p.Werr, err)
+		}
+		for _, f := range files {
+			es = append(es, &Event{Path: f, Op: e.Op})
+		}
+	}
+	return es
+}
+
 // Stop will discontinue watching for file changes
+// Events not yet processed are dropped, Start returns when running Workflows finish
 func (p *Pipeline) Stop() (err error) {
 	if p.watcher == nil {
 		return errors.New("Pipeline was not started or has not completed")
 	}
+	if p.quit != nil {
+		p.quitOnce.Do(func() { close(p.quit) })
+	}
+	for _, t := range p.Triggers {
+		if terr := t.Stop(); terr != nil && p.Verbose {
+			fmt.Fprintln(p.Wout, terr)
+		}
+	}
 	err = p.watcher.Stop()
 	if err != nil {
 		fmt.Fprintln(p.Wout, err)
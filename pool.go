@@ -0,0 +1,210 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+)
+
+// A Resourcer is a Tasker that uses one or more named resources
+// A Pipeline limits the number of Tasks using a resource at the same time (See Pipeline.Limit)
+// The limits also apply to a Resourcer wrapped by another Task such as a FreshTask or a cached Task
+type Resourcer interface {
+	Tasker
+	Resources() []string
+}
+
+type resourceTask struct {
+	Tasker
+	resources []string
+}
+
+// NewResourceTask returns a Tasker that runs t while holding the named resources
+// i.e. NewResourceTask(gotask.NewGoBuildTask(), "go-build")
+func NewResourceTask(t Tasker, resources ...string) Tasker {
+	return &resourceTask{Tasker: t, resources: resources}
+}
+
+func (t *resourceTask) Resources() []string {
+	return t.resources
+}
+
+type job struct {
+	priority int
+	seq      uint64
+	run      func()
+}
+
+// jobQueue orders jobs by priority, then by arrival
+type jobQueue []*job
+
+func (q jobQueue) Len() int { return len(q) }
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q jobQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *jobQueue) Push(x interface{}) { *q = append(*q, x.(*job)) }
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	j := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return j
+}
+
+// workerPool runs queued jobs on a fixed number of goroutines
+type workerPool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   jobQueue
+	seq     uint64
+	closed  bool
+	workers sync.WaitGroup
+}
+
+func newWorkerPool(workers int) *workerPool {
+	wp := new(workerPool)
+	wp.cond = sync.NewCond(&wp.mu)
+	wp.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go wp.work()
+	}
+	return wp
+}
+
+// submit queues f, once the pool is closed f is run on its own goroutine
+func (wp *workerPool) submit(priority int, f func()) {
+	wp.mu.Lock()
+	if wp.closed {
+		wp.mu.Unlock()
+		go f()
+		return
+	}
+	wp.seq++
+	heap.Push(&wp.queue, &job{priority: priority, seq: wp.seq, run: f})
+	wp.mu.Unlock()
+	wp.cond.Signal()
+}
+
+// close lets the workers exit once the queued jobs have run
+func (wp *workerPool) close() {
+	wp.mu.Lock()
+	wp.closed = true
+	wp.mu.Unlock()
+	wp.cond.Broadcast()
+}
+
+func (wp *workerPool) work() {
+	defer wp.workers.Done()
+	for {
+		wp.mu.Lock()
+		for wp.queue.Len() == 0 && !wp.closed {
+			wp.cond.Wait()
+		}
+		if wp.queue.Len() == 0 {
+			wp.mu.Unlock()
+			return
+		}
+		j := heap.Pop(&wp.queue).(*job)
+		wp.mu.Unlock()
+		j.run()
+	}
+}
+
+// Limit sets the number of Tasks that can use the named resource at the same time
+// Tasks declare the resources they use by implementing Resourcer (See NewResourceTask)
+// Resources that have no limit set, or a limit less than 1, are not restricted
+func (p *Pipeline) Limit(resource string, n int) {
+	p.semMu.Lock()
+	defer p.semMu.Unlock()
+	if p.sems == nil {
+		p.sems = make(map[string]chan struct{})
+	}
+	if n < 1 {
+		delete(p.sems, resource)
+		return
+	}
+	p.sems[resource] = make(chan struct{}, n)
+}
+
+// resources returns the resources used by t and the Tasks it wraps, i.e. a FreshTask of a resource Task
+func resources(t Tasker) (names []string) {
+	for t != nil {
+		if r, ok := t.(Resourcer); ok {
+			names = append(names, r.Resources()...)
+		}
+		w, ok := t.(wrapper)
+		if !ok {
+			break
+		}
+		t = w.unwrap()
+	}
+	return
+}
+
+// acquire blocks until all of the resources used by t are available
+// returns a function that releases them
+func (p *Pipeline) acquire(t Tasker) (release func()) {
+	release = func() {}
+	if p == nil {
+		return
+	}
+	names := resources(t)
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names) // always acquire in the same order to avoid deadlocks
+
+	var held []chan struct{}
+	p.semMu.Lock()
+	for i, n := range names {
+		if i > 0 && names[i-1] == n {
+			continue
+		}
+		if s, ok := p.sems[n]; ok {
+			held = append(held, s)
+		}
+	}
+	p.semMu.Unlock()
+
+	for _, s := range held {
+		s <- struct{}{}
+	}
+	return func() {
+		for _, s := range held {
+			<-s
+		}
+	}
+}
+
+// spawn runs f concurrently
+// If MaxWorkers is set f is queued by priority until a worker is free
+func (p *Pipeline) spawn(priority int, f func()) {
+	if p == nil || p.MaxWorkers < 1 {
+		go f()
+		return
+	}
+	p.poolMu.Lock()
+	if p.pool == nil {
+		p.pool = newWorkerPool(p.MaxWorkers)
+	}
+	wp := p.pool
+	p.poolMu.Unlock()
+	wp.submit(priority, f)
+}
+
+// closePool lets the workers started by spawn exit, a later spawn starts new ones
+func (p *Pipeline) closePool() {
+	p.poolMu.Lock()
+	wp := p.pool
+	p.pool = nil
+	p.poolMu.Unlock()
+	if wp != nil {
+		wp.close()
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type sleepTask struct {
+	mu            *sync.Mutex
+	running, most *int
+}
+
+func (t sleepTask) Run(i *TaskInfo) (err error) {
+	t.mu.Lock()
+	*t.running++
+	if *t.running > *t.most {
+		*t.most = *t.running
+	}
+	t.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	t.mu.Lock()
+	*t.running--
+	t.mu.Unlock()
+	return
+}
+
+func TestPipelineLimit(t *testing.T) {
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Limit("go-build", 1)
+
+	var mu sync.Mutex
+	var running, most int
+	tsk := NewResourceTask(sleepTask{&mu, &running, &most}, "go-build", "go-build")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			NewWorkflow(tsk).Run(&TaskInfo{pipeline: p})
+		}()
+	}
+	wg.Wait()
+	if most != 1 {
+		t.Errorf("Expected 1 task holding go-build at a time got %v", most)
+	}
+}
+
+func TestPipelineLimitWrapped(t *testing.T) {
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Limit("go-build", 1)
+
+	var mu sync.Mutex
+	var running, most int
+	tsk := NewFreshTask(NewResourceTask(sleepTask{&mu, &running, &most}, "go-build"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			NewWorkflow(tsk).Run(&TaskInfo{pipeline: p})
+		}()
+	}
+	wg.Wait()
+	if most != 1 {
+		t.Errorf("Expected 1 wrapped task holding go-build at a time got %v", most)
+	}
+}
+
+func TestPipelineWorkers(t *testing.T) {
+	p := NewPipeline("Test Pipeline", Silent)
+	p.MaxWorkers = 1
+
+	block := make(chan struct{})
+	done := make(chan int, 3)
+	p.spawn(0, func() { <-block })
+	p.spawn(1, func() { done <- 1 })
+	p.spawn(5, func() { done <- 5 })
+	p.spawn(1, func() { done <- 2 })
+	close(block)
+
+	for _, e := range []int{5, 1, 2} {
+		if g := <-done; g != e {
+			t.Errorf("Expected job %v got %v", e, g)
+		}
+	}
+}
+
+func TestPipelineWorkersClose(t *testing.T) {
+	p := NewPipeline("Test Pipeline", Silent)
+	p.MaxWorkers = 2
+
+	done := make(chan int, 3)
+	p.spawn(0, func() { done <- 1 })
+	wp := p.pool
+	p.closePool()
+	exited := make(chan struct{})
+	go func() {
+		wp.workers.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the workers to exit when the pool is closed")
+	}
+
+	wp.submit(0, func() { done <- 2 })
+	p.spawn(0, func() { done <- 3 })
+	if p.pool == nil || p.pool == wp {
+		t.Errorf("Expected a new pool after close")
+	}
+	got := 0
+	for i := 0; i < 3; i++ {
+		select {
+		case g := <-done:
+			got += g
+		case <-time.After(time.Second):
+			t.Fatalf("Expected all jobs to run got %v", got)
+		}
+	}
+	p.closePool()
+}
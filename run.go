@@ -0,0 +1,193 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A pass tracks the Workflow runs started for a set of events including chained Workflows
+type pass struct {
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	runs   int
+	failed []string
+}
+
+func (ps *pass) begin() {
+	if ps != nil {
+		ps.wg.Add(1)
+	}
+}
+
+func (ps *pass) end(wf *Workflow, src string, err error) {
+	if ps == nil {
+		return
+	}
+	ps.mu.Lock()
+	ps.runs++
+	if err != nil {
+		ps.failed = append(ps.failed, fmt.Sprintf("%v for %v", wf.Name, src))
+	}
+	ps.mu.Unlock()
+	ps.wg.Done()
+}
+
+func (ps *pass) wait() *RunResult {
+	ps.wg.Wait()
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return &RunResult{Runs: ps.runs, Failed: append([]string(nil), ps.failed...)}
+}
+
+// A RunResult summarizes the Workflow runs of a Pipeline pass
+// A RunResult with failures is also an error
+type RunResult struct {
+	Runs   int      // Number of Workflow runs including chained Workflows
+	Failed []string // Workflow and file name of each failed run
+}
+
+// OK reports whether every Workflow run succeeded
+func (r *RunResult) OK() bool {
+	return len(r.Failed) == 0
+}
+
+// ExitCode returns 0 if every Workflow run succeeded and 1 otherwise
+func (r *RunResult) ExitCode() int {
+	if r.OK() {
+		return 0
+	}
+	return 1
+}
+
+func (r *RunResult) Error() string {
+	return fmt.Sprintf("%v of %v Workflow runs failed\n%v", len(r.Failed), r.Runs, strings.Join(r.Failed, "\n"))
+}
+
+// RunOnce runs every matching Workflow for each file in paths without watching for changes
+// If no paths are given all of the files in the Pipeline Watches are used, a directory added with Watch
+// only contributes its own files while WatchRecursive directories include their sub directories
+// Directories in paths are walked recursively skipping hidden directories
+// Workflows are matched as if each file had just been created
+// RunOnce waits for all of the Workflows to finish and returns the combined result
+// An error is returned if a path could not be read
+// Combined with FreshTask only out of date Targets are rebuilt
+//
+//	res, err := p.RunOnce()
+//	if err != nil || !res.OK() {
+//		os.Exit(1)
+//	}
+func (p *Pipeline) RunOnce(paths ...string) (*RunResult, error) {
+	p.defaults()
+	var files []string
+	var err error
+	if len(paths) == 0 {
+		files, err = p.watchedFiles()
+	} else {
+		files, err = p.files(paths)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ps := new(pass)
+	for _, f := range files {
+		p.run(&Event{Path: f, Op: Create}, ps)
+	}
+	res := p.result(ps)
+	p.closePool()
+	return res, nil
+}
+
+// result waits for the Workflow runs of a pass and reports them
+func (p *Pipeline) result(ps *pass) *RunResult {
+	res := ps.wait()
+	if p.Verbose {
+		fmt.Fprintf(p.Wout, "> Pipeline %v ran %v Workflows, %v failed\n", p.Name, res.Runs, len(res.Failed))
+	}
+	return res
+}
+
+// initialRun queues a run of the Workflows over the existing files on the Workflow goroutine
+// so it is held like any other Event while the Pipeline is paused
+func (p *Pipeline) initialRun() {
+	files, err := p.watchedFiles()
+	if err != nil {
+		fmt.Fprintln(p.Werr, err)
+		return
+	}
+	p.do(func() {
+		ps := new(pass)
+		for _, f := range files {
+			e := &Event{Path: f, Op: Create}
+			if !p.hold(e) {
+				p.run(e, ps)
+			}
+		}
+		// chained and concurrent Workflows may still be running, do not block the Workflow goroutine
+		go func() {
+			if res := p.result(ps); !res.OK() {
+				fmt.Fprintln(p.Werr, res)
+			}
+		}()
+	})
+}
+
+// files returns the files in paths, walking directories
+func (p *Pipeline) files(paths []string) (files []string, err error) {
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		ap, err := AbsPath(path)
+		if err != nil {
+			return nil, err
+		}
+		err = filepath.Walk(ap, func(f string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				if f != ap && IsHidden(info.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// watchedFiles returns the files in the Watches
+// Each watched directory is listed on its own, the sub directories of a recursive watch are watches themselves
+func (p *Pipeline) watchedFiles() (files []string, err error) {
+	if p.OSX {
+		return p.files(p.Watches) // OSX watches are always recursive
+	}
+	for _, w := range p.Watches {
+		fis, err := ioutil.ReadDir(w)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, fi := range fis {
+			if !fi.IsDir() {
+				files = append(files, filepath.Join(w, fi.Name()))
+			}
+		}
+	}
+	return
+}
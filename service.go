@@ -0,0 +1,42 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import "fmt"
+
+// A Service is something that runs alongside a Pipeline such as a set of server processes or a dev web server
+// Start should return once the Service is running
+type Service interface {
+	Start() error
+	Stop() error
+}
+
+// Manage adds Services that are started with the Pipeline and stopped when it shuts down
+func (p *Pipeline) Manage(svcs ...Service) {
+	p.services = append(p.services, svcs...)
+}
+
+// startServices starts the Services, stopping the ones already started if one fails
+func (p *Pipeline) startServices() error {
+	for i, s := range p.services {
+		if err := s.Start(); err != nil {
+			p.stopServices(p.services[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+// stopServices stops svcs in reverse order and returns the first error
+func (p *Pipeline) stopServices(svcs []Service) (err error) {
+	for i := len(svcs) - 1; i >= 0; i-- {
+		if serr := svcs[i].Stop(); serr != nil {
+			fmt.Fprintln(p.Werr, serr)
+			if err == nil {
+				err = serr
+			}
+		}
+	}
+	return
+}
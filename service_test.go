@@ -0,0 +1,56 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+type testService struct {
+	name string
+	fail bool
+	log  *[]string
+}
+
+func (s testService) Start() error {
+	if s.fail {
+		return errors.New(s.name + " failed")
+	}
+	*s.log = append(*s.log, "start "+s.name)
+	return nil
+}
+
+func (s testService) Stop() error {
+	*s.log = append(*s.log, "stop "+s.name)
+	return nil
+}
+
+func TestServices(t *testing.T) {
+	var log []string
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Wout, p.Werr = ioutil.Discard, ioutil.Discard
+	p.Manage(testService{name: "a", log: &log}, testService{name: "b", log: &log})
+
+	if err := p.startServices(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.shutdown(); err != nil {
+		t.Error(err)
+	}
+	if s := strings.Join(log, ","); s != "start a,start b,stop b,stop a" {
+		t.Errorf("Expected Services stopped in reverse order got %v", s)
+	}
+
+	log = nil
+	p.Manage(testService{name: "c", fail: true, log: &log})
+	if err := p.startServices(); err == nil {
+		t.Error("Expected error for failed Service")
+	}
+	if s := strings.Join(log, ","); s != "start a,start b,stop b,stop a" {
+		t.Errorf("Expected started Services stopped after a failure got %v", s)
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+// +build !windows
+
+package shelltask
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var stopSignal os.Signal = syscall.SIGTERM
+
+// setGroup starts cmd in a new process group
+func setGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalGroup sends sig to every process in the group led by p
+func signalGroup(p *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+	return syscall.Kill(-p.Pid, s)
+}
+
+// shellCommand returns the command and arguments to run line with the shell
+func shellCommand(line string) (string, []string) {
+	return "sh", []string{"-c", line}
+}
@@ -0,0 +1,26 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+// +build windows
+
+package shelltask
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Windows can not deliver signals other than Kill
+var stopSignal = os.Kill
+
+// setGroup does nothing, Windows process groups are not supported
+func setGroup(cmd *exec.Cmd) {}
+
+// signalGroup sends sig to p only
+func signalGroup(p *os.Process, sig os.Signal) error {
+	return p.Signal(sig)
+}
+
+// shellCommand returns the command and arguments to run line with the shell
+func shellCommand(line string) (string, []string) {
+	return "cmd", []string{"/C", line}
+}
@@ -0,0 +1,245 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package shelltask
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/dshills/goauto"
+)
+
+var procLine = regexp.MustCompile(`^([A-Za-z0-9_.-]+):\s*(.+)$`)
+
+// ANSI colors for the process name prefixes
+var procColors = []int{36, 33, 32, 35, 34, 31}
+
+// A Supervisor runs a set of named processes together such as the ones in a Procfile
+// Each line of output is prefixed with the name of its process, color coded unless NoColor is set
+// A Supervisor is a goauto.Service, use Pipeline.Manage to start the processes with a Pipeline
+// and stop them when it shuts down
+type Supervisor struct {
+	Tout, Terr io.Writer // Writers for the process output, default to os.Stdout and os.Stderr
+	NoColor    bool
+	Verbose    bool
+	names      []string
+	procs      map[string]*RestartTask
+	infos      map[string]*goauto.TaskInfo
+	mu         sync.Mutex // guards infos and keeps lines from different processes whole
+}
+
+// NewSupervisor returns an empty Supervisor, see Add
+func NewSupervisor() *Supervisor {
+	return &Supervisor{procs: make(map[string]*RestartTask)}
+}
+
+// LoadProcfile returns a Supervisor for the processes in the Procfile fpath
+func LoadProcfile(fpath string) (*Supervisor, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseProcfile(f)
+}
+
+// ParseProcfile returns a Supervisor for the processes read from r
+// Each line is name: command, the command is run by the shell
+// Blank lines and lines starting with # are ignored
+//
+//	api: go run ./cmd/api
+//	worker: go run ./cmd/worker -queue default
+//	web: npm run watch
+func ParseProcfile(r io.Reader) (*Supervisor, error) {
+	s := NewSupervisor()
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := procLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("Procfile line %v: expected name: command", n)
+		}
+		cmd, args := shellCommand(m[2])
+		if err := s.Add(m[1], NewRestartTask(cmd, args...)); err != nil {
+			return nil, fmt.Errorf("Procfile line %v: %v", n, err)
+		}
+	}
+	return s, sc.Err()
+}
+
+// Add adds a process to the Supervisor
+// The RestartTask can be configured with Ready checks, Supervise and so on
+func (s *Supervisor) Add(name string, r *RestartTask) error {
+	if _, ok := s.procs[name]; ok {
+		return fmt.Errorf("Process %v already added", name)
+	}
+	s.names = append(s.names, name)
+	s.procs[name] = r
+	return nil
+}
+
+// Names returns the names of the processes in the order they were added
+func (s *Supervisor) Names() []string {
+	return append([]string(nil), s.names...)
+}
+
+// Process returns the RestartTask for the process name or nil
+func (s *Supervisor) Process(name string) *RestartTask {
+	return s.procs[name]
+}
+
+// Start starts all of the processes
+// If one fails to start the others are stopped
+func (s *Supervisor) Start() error {
+	for i, name := range s.names {
+		if err := s.procs[name].Restart(s.info(name)); err != nil {
+			s.stop(s.names[:i])
+			return fmt.Errorf("Process %v: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Stop stops all of the processes
+func (s *Supervisor) Stop() error {
+	return s.stop(s.names)
+}
+
+func (s *Supervisor) stop(names []string) (err error) {
+	for i := len(names) - 1; i >= 0; i-- {
+		if kerr := s.procs[names[i]].Kill(s.info(names[i])); kerr != nil && err == nil {
+			err = kerr
+		}
+	}
+	return
+}
+
+// Restart restarts the named processes or all of them if no names are given
+func (s *Supervisor) Restart(names ...string) error {
+	if len(names) == 0 {
+		names = s.names
+	}
+	for _, name := range names {
+		r, ok := s.procs[name]
+		if !ok {
+			return fmt.Errorf("No process named %v", name)
+		}
+		if err := r.Restart(s.info(name)); err != nil {
+			return fmt.Errorf("Process %v: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// info returns the TaskInfo with the prefixed writers for the process name
+func (s *Supervisor) info(name string) *goauto.TaskInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.infos == nil {
+		s.infos = make(map[string]*goauto.TaskInfo)
+	}
+	if i, ok := s.infos[name]; ok {
+		return i
+	}
+	if s.Tout == nil {
+		s.Tout = os.Stdout
+	}
+	if s.Terr == nil {
+		s.Terr = os.Stderr
+	}
+	width := 0
+	for _, n := range s.names {
+		if len(n) > width {
+			width = len(n)
+		}
+	}
+	prefix := fmt.Sprintf("%-*s | ", width, name)
+	if !s.NoColor {
+		for i, n := range s.names {
+			if n == name {
+				prefix = fmt.Sprintf("\x1b[%dm%s\x1b[0m", procColors[i%len(procColors)], prefix)
+			}
+		}
+	}
+	i := &goauto.TaskInfo{
+		Tout:    &prefixWriter{mu: &s.mu, w: s.Tout, prefix: prefix},
+		Terr:    &prefixWriter{mu: &s.mu, w: s.Terr, prefix: prefix},
+		Verbose: s.Verbose,
+	}
+	s.infos[name] = i
+	return i
+}
+
+// prefixWriter writes each complete line with a prefix
+// A last line without a newline is written by flush when the process exits
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (pw *prefixWriter) Write(b []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.buf = append(pw.buf, b...)
+	for {
+		i := bytes.IndexByte(pw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(pw.w, "%s%s", pw.prefix, pw.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		pw.buf = pw.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// flush writes a partial line left in the buffer ending it with a newline
+func (pw *prefixWriter) flush() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if len(pw.buf) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintf(pw.w, "%s%s\n", pw.prefix, pw.buf)
+	pw.buf = nil
+	return err
+}
+
+type supervisorTask struct {
+	s     *Supervisor
+	names []string
+}
+
+// NewSupervisorTask returns a goauto.Tasker that restarts the named processes of s
+// or all of them if no names are given
+// goauto.TaskInfo.Target is set to goauto.TaskInfo.Src
+func NewSupervisorTask(s *Supervisor, names ...string) goauto.Tasker {
+	return &supervisorTask{s: s, names: names}
+}
+
+// Run will restart the processes
+func (st *supervisorTask) Run(info *goauto.TaskInfo) (err error) {
+	info.Target = info.Src
+	info.Buf.Reset()
+	if info.Verbose {
+		names := "all processes"
+		if len(st.names) > 0 {
+			names = strings.Join(st.names, ", ")
+		}
+		fmt.Fprintf(info.Tout, ">>> Restarting %v\n", names)
+	}
+	return st.s.Restart(st.names...)
+}
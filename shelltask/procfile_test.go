@@ -0,0 +1,112 @@
+package shelltask
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/goauto"
+)
+
+func TestParseProcfile(t *testing.T) {
+	s, err := ParseProcfile(strings.NewReader("# dev processes\napi: echo api; sleep 30\n\nworker:   echo worker\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Join(s.Names(), ","); n != "api,worker" {
+		t.Errorf("Expected api,worker got %v", n)
+	}
+	if r := s.Process("worker"); r == nil || r.Cmd != "sh" || r.Args[1] != "echo worker" {
+		t.Errorf("Expected worker run by the shell got %+v", r)
+	}
+
+	if _, err = ParseProcfile(strings.NewReader("api: a\nnot a process\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected error for line 2 got %v", err)
+	}
+	if _, err = ParseProcfile(strings.NewReader("api: a\napi: b\n")); err == nil {
+		t.Error("Expected error for duplicate name")
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	out := new(syncBuffer)
+	s := NewSupervisor()
+	s.Tout, s.NoColor = out, true
+	s.Add("a", NewRestartTask("true"))
+	s.Add("long", NewRestartTask("true"))
+	w := s.info("a").Tout
+	w.Write([]byte("one\ntw"))
+	w.Write([]byte("o\n"))
+	if o := out.String(); o != "a    | one\na    | two\n" {
+		t.Errorf("Unexpected output %q", o)
+	}
+
+	w.Write([]byte("three"))
+	w.(*prefixWriter).flush()
+	if o := out.String(); !strings.HasSuffix(o, "a    | two\na    | three\n") {
+		t.Errorf("Expected the partial line flushed got %q", o)
+	}
+
+	// a last line without a newline is written when the process exits
+	s.Add("b", NewRestartTask("printf", "last"))
+	if err := s.Restart("b"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && !strings.Contains(out.String(), "b    | last"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if o := out.String(); !strings.Contains(o, "b    | last\n") {
+		t.Errorf("Expected the last line on exit got %q", o)
+	}
+
+	s.NoColor, s.infos = false, nil
+	s.info("long").Tout.Write([]byte("x\n"))
+	if o := out.String(); !strings.HasSuffix(o, "\x1b[33mlong | \x1b[0mx\n") {
+		t.Errorf("Expected colored prefix got %q", o)
+	}
+}
+
+func TestSupervisor(t *testing.T) {
+	out := new(syncBuffer)
+	s, err := ParseProcfile(strings.NewReader("api: echo api $$; sleep 30\nworker: echo worker $$; sleep 30\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Tout, s.Terr, s.NoColor = out, ioutil.Discard, true
+	for _, n := range s.Names() {
+		s.Process(n).GracePeriod = time.Second
+	}
+
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if o := out.String(); !strings.Contains(o, "api    | api ") || !strings.Contains(o, "worker | worker ") {
+		t.Errorf("Expected prefixed output got %q", o)
+	}
+
+	ti := &goauto.TaskInfo{Src: "main.go", Tout: ioutil.Discard, Terr: ioutil.Discard}
+	if err = NewSupervisorTask(s, "worker").Run(ti); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := strings.Count(out.String(), "worker | worker "); n != 2 {
+		t.Errorf("Expected worker restarted got %q", out.String())
+	}
+	if n := strings.Count(out.String(), "api    | api "); n != 1 {
+		t.Errorf("Expected api left running got %q", out.String())
+	}
+	if err = s.Restart("nope"); err == nil {
+		t.Error("Expected error for unknown process")
+	}
+
+	if err = s.Stop(); err != nil {
+		t.Error(err)
+	}
+	for _, n := range s.Names() {
+		if st := s.Process(n).State(); st != ProcessStopped {
+			t.Errorf("Expected %v stopped got %v", n, st)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package shelltask
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReadyTimeout = 30 * time.Second
+	readyPoll           = 100 * time.Millisecond
+	maxOutput           = 64 * 1024 // output kept for error reports
+)
+
+// A ReadyCheck reports whether a started process is ready
+// out is the stdout and stderr captured from the process so far
+type ReadyCheck func(out []byte) bool
+
+// TCPReady returns a ReadyCheck that passes once addr accepts connections
+func TCPReady(addr string) ReadyCheck {
+	return func([]byte) bool {
+		c, err := net.DialTimeout("tcp", addr, time.Second)
+		if err != nil {
+			return false
+		}
+		c.Close()
+		return true
+	}
+}
+
+// HTTPReady returns a ReadyCheck that passes once a GET of url returns a 2xx status
+func HTTPReady(url string) ReadyCheck {
+	client := &http.Client{Timeout: time.Second}
+	return func([]byte) bool {
+		resp, err := client.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode >= 200 && resp.StatusCode < 300
+	}
+}
+
+// LogReady returns a ReadyCheck that passes once the process output matches the regex pattern
+// i.e. LogReady("listening on :8080")
+// An invalid regexp pattern will return an error
+func LogReady(pattern string) (ReadyCheck, error) {
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return func(out []byte) bool {
+		return r.Match(out)
+	}, nil
+}
+
+// output keeps the most recent output of a process
+type output struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (o *output) Write(b []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, b...)
+	if len(o.buf) > maxOutput {
+		o.buf = append([]byte(nil), o.buf[len(o.buf)-maxOutput:]...)
+	}
+	return len(b), nil
+}
+
+func (o *output) Bytes() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]byte(nil), o.buf...)
+}
+
+// ready reports whether all of the checks pass
+func (p *process) ready(checks []ReadyCheck) bool {
+	out := p.out.Bytes()
+	for _, c := range checks {
+		if !c(out) {
+			return false
+		}
+	}
+	return true
+}
+
+// waitReady waits for all of the checks to pass
+// An error with the captured output is returned if the process exits or is not ready before timeout
+func (p *process) waitReady(name string, checks []ReadyCheck, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	tick := time.NewTicker(readyPoll)
+	defer tick.Stop()
+	for {
+		select {
+		case <-p.done:
+			if p.err == nil {
+				return fmt.Errorf("Process %v exited before it was ready\n%s", name, p.out.Bytes())
+			}
+			return fmt.Errorf("Process %v exited before it was ready: %v\n%s", name, p.err, p.out.Bytes())
+		default:
+		}
+		if p.ready(checks) {
+			return nil
+		}
+		select {
+		case <-p.done:
+		case <-tick.C:
+		case <-deadline:
+			return fmt.Errorf("Process %v was not ready after %v\n%s", name, timeout, p.out.Bytes())
+		}
+	}
+}
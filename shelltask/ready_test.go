@@ -0,0 +1,80 @@
+package shelltask
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/goauto"
+)
+
+func TestReadyChecks(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	if !TCPReady(addr)(nil) {
+		t.Error("Expected TCPReady to pass")
+	}
+	l.Close()
+	if TCPReady(addr)(nil) {
+		t.Error("Expected TCPReady to fail")
+	}
+
+	status := http.StatusServiceUnavailable
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+	if HTTPReady(ts.URL)(nil) {
+		t.Error("Expected HTTPReady to fail")
+	}
+	status = http.StatusNoContent
+	if !HTTPReady(ts.URL)(nil) {
+		t.Error("Expected HTTPReady to pass")
+	}
+
+	if _, err = LogReady("("); err == nil {
+		t.Error("Expected an error for a bad pattern")
+	}
+	lr, _ := LogReady(`listening on :\d+`)
+	if lr([]byte("starting\n")) || !lr([]byte("starting\nlistening on :8080\n")) {
+		t.Error("LogReady did not match the output")
+	}
+}
+
+func TestRestartReady(t *testing.T) {
+	ti := goauto.TaskInfo{Tout: ioutil.Discard, Terr: ioutil.Discard, Verbose: goauto.Silent}
+	lr, _ := LogReady("ready")
+
+	tsk := NewRestartTask("sh", "-c", "sleep 0.2; echo ready; sleep 30")
+	tsk.Ready = []ReadyCheck{lr}
+	if err := tsk.Restart(&ti); err != nil {
+		t.Error(err)
+	}
+	tsk.Kill(&ti)
+
+	tsk = NewRestartTask("sh", "-c", "echo boom >&2; exit 3")
+	tsk.Ready = []ReadyCheck{lr}
+	err := tsk.Restart(&ti)
+	if err == nil || !strings.Contains(err.Error(), "boom") || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("Expected exit error with output got %v", err)
+	}
+
+	tsk = NewRestartTask("sh", "-c", "echo waiting; sleep 30")
+	tsk.Ready = []ReadyCheck{lr}
+	tsk.ReadyTimeout = 300 * time.Millisecond
+	tsk.GracePeriod = 200 * time.Millisecond
+	err = tsk.Restart(&ti)
+	if err == nil || !strings.Contains(err.Error(), "not ready") || !strings.Contains(err.Error(), "waiting") {
+		t.Errorf("Expected not ready error with output got %v", err)
+	}
+	if tsk.proc != nil {
+		t.Error("Expected the process to be killed")
+	}
+}
@@ -6,16 +6,50 @@ package shelltask
 import (
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
 	"os/exec"
+	"sync"
+	"time"
 
 	"github.com/dshills/goauto"
 )
 
+const defaultGrace = 5 * time.Second
+
 // A RestartTask represents a task to launch or relaunch an executable file
+// The executable is started in its own process group so that any processes it starts,
+// i.e. the binary built by go run, are stopped with it
+// With Supervise set an application that crashes between changes is restarted automatically (See State)
 type RestartTask struct {
-	ecmd *exec.Cmd
-	Cmd  string
-	Args []string
+	Cmd          string
+	Args         []string
+	StopSignal   os.Signal     // Signal sent to stop the process group, defaults to SIGTERM
+	GracePeriod  time.Duration // Time to wait after StopSignal before killing the process group, defaults to 5s
+	Addr         string        // Optional host:port the process listens on, must be free before starting a new instance
+	Ready        []ReadyCheck  // Checks that must pass before Restart returns, i.e. TCPReady, HTTPReady or LogReady
+	ReadyTimeout time.Duration // Time to wait for the Ready checks, defaults to 30s
+	Supervise    bool          // Restart the application if it exits on its own
+	Backoff      time.Duration // Delay before the first automatic restart, doubled for each further crash, defaults to 1s
+	MaxRestarts  int           // Automatic restarts in a row before giving up, defaults to 5
+	mu           sync.Mutex
+	proc         *process
+	started      time.Time
+	crashes      int
+	gen          int // incremented to cancel a pending automatic restart
+	timer        *time.Timer
+	stateMu      sync.Mutex
+	state        ProcessState
+}
+
+// process is a running instance of the executable
+type process struct {
+	cmd  *exec.Cmd
+	done chan struct{} // closed when the process exits
+	err  error         // result of Wait, set before done is closed
+	out  output        // recent stdout and stderr
 }
 
 // NewRestartTask returns a ReloadTask
@@ -23,55 +57,179 @@ func NewRestartTask(cmd string, args ...string) *RestartTask {
 	return &RestartTask{Cmd: cmd, Args: args}
 }
 
+func (r *RestartTask) grace() time.Duration {
+	if r.GracePeriod > 0 {
+		return r.GracePeriod
+	}
+	return defaultGrace
+}
+
 // Restart will launch or relaunch the application
+// If Ready checks are set Restart waits for them to pass, an error with the captured output
+// is returned if the application exits or is not ready within ReadyTimeout
 func (r *RestartTask) Restart(t *goauto.TaskInfo) (err error) {
 	if r.Cmd == "" {
 		return errors.New("Cmd not set, Nothing to run")
 	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	err = r.Kill(t)
-	if err != nil {
+	if err = r.kill(t); err != nil {
 		return
 	}
+	if r.Addr != "" {
+		if err = waitFree(r.Addr, r.grace()); err != nil {
+			return
+		}
+	}
 
-	r.ecmd = exec.Command(r.Cmd, r.Args...)
-	r.ecmd.Stdout = t.Tout
-	r.ecmd.Stderr = t.Terr
+	r.crashes = 0
+	if err = r.start(t); err != nil {
+		r.setState(ProcessCrashed)
+	}
+	return
+}
 
-	err = r.ecmd.Start()
+// start launches the application and waits for the Ready checks
+func (r *RestartTask) start(t *goauto.TaskInfo) (err error) {
+	r.setState(ProcessStarting)
+	cmd := exec.Command(r.Cmd, r.Args...)
+	p := &process{cmd: cmd, done: make(chan struct{})}
+	cmd.Stdout = io.MultiWriter(t.Tout, &p.out)
+	cmd.Stderr = io.MultiWriter(t.Terr, &p.out)
+	setGroup(cmd)
+	if err = cmd.Start(); err != nil {
+		return
+	}
 	go func() {
-		err = r.ecmd.Wait()
+		p.err = cmd.Wait()
+		flushOutput(t.Tout, t.Terr)
+		close(p.done)
 	}()
+	r.proc = p
+	r.started = time.Now()
 	if t.Verbose {
 		fmt.Fprintf(t.Tout, "Process %v started\n", r.Cmd)
 	}
+
+	if len(r.Ready) > 0 {
+		timeout := r.ReadyTimeout
+		if timeout <= 0 {
+			timeout = defaultReadyTimeout
+		}
+		if err = p.waitReady(r.Cmd, r.Ready, timeout); err != nil {
+			r.kill(t)
+			return
+		}
+		if t.Verbose {
+			fmt.Fprintf(t.Tout, "Process %v ready\n", r.Cmd)
+		}
+	}
+	r.setState(ProcessRunning)
+	info := &goauto.TaskInfo{Tout: t.Tout, Terr: t.Terr, Verbose: t.Verbose}
+	go func() {
+		<-p.done
+		r.exited(p, info)
+	}()
 	return
 }
 
 // Kill will stop the running task
+// StopSignal is sent to the process group, if the process has not exited after GracePeriod
+// the whole group is killed
 func (r *RestartTask) Kill(t *goauto.TaskInfo) (err error) {
-	defer func() { r.ecmd = nil }()
-	if r.ecmd == nil || r.ecmd.Process == nil {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.kill(t)
+}
+
+func (r *RestartTask) kill(t *goauto.TaskInfo) (err error) {
+	r.cancelRestart()
+	r.setState(ProcessStopped)
+	p := r.proc
+	if p == nil {
 		return
 	}
-	if r.ecmd.ProcessState != nil && r.ecmd.ProcessState.Exited() {
+	r.proc = nil
+	// the group can outlive its leader, i.e. a server left running by a shell script, so it is signaled either way
+	exited := false
+	select {
+	case <-p.done:
+		exited = true
 		if t.Verbose {
 			fmt.Fprintf(t.Tout, "Process %v already exited\n", r.Cmd)
 		}
-		return
+	default:
 	}
 
-	if err = r.ecmd.Process.Kill(); err != nil {
-		return
+	sig := r.StopSignal
+	if sig == nil {
+		sig = stopSignal
+	}
+	signalGroup(p.cmd.Process, sig)
+	if exited {
+		if r.Addr != "" {
+			waitFree(r.Addr, r.grace())
+		}
+	} else {
+		select {
+		case <-p.done:
+		case <-time.After(r.grace()):
+			if t.Verbose {
+				fmt.Fprintf(t.Tout, "Process %v did not stop after %v\n", r.Cmd, r.grace())
+			}
+		}
+	}
+	// clean up anything left in the group
+	signalGroup(p.cmd.Process, os.Kill)
+	select {
+	case <-p.done:
+	case <-time.After(r.grace()):
+		return fmt.Errorf("Process %v could not be killed", r.Cmd)
 	}
 
-	err = r.ecmd.Process.Release()
-	if t.Verbose {
+	if r.Addr != "" {
+		err = waitFree(r.Addr, r.grace())
+	}
+	if t.Verbose && !exited {
 		fmt.Fprintf(t.Tout, "Process %v killed\n", r.Cmd)
 	}
 	return
 }
 
+// flushOutput writes the partial last lines held by output writers such as the Supervisor prefixes
+func flushOutput(ws ...io.Writer) {
+	for _, w := range ws {
+		if f, ok := w.(interface {
+			flush() error
+		}); ok {
+			f.flush()
+		}
+	}
+}
+
+// waitFree waits until nothing is listening on addr
+func waitFree(addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		c, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err != nil {
+			return nil
+		}
+		c.Close()
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%v is still in use", addr)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Stop will stop the running application
+// Satisfies goauto.Stopper so a Pipeline stops the application when it shuts down
+func (r *RestartTask) Stop() error {
+	return r.Kill(&goauto.TaskInfo{Tout: ioutil.Discard, Terr: ioutil.Discard})
+}
+
 // Run will restart the application in Cmd
 func (r *RestartTask) Run(t *goauto.TaskInfo) (err error) {
 	return r.Restart(t)
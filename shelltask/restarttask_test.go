@@ -1,7 +1,11 @@
 package shelltask
 
 import (
+	"bytes"
 	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -63,3 +67,86 @@ func TestRestartWorkflow(t *testing.T) {
 	wf := goauto.NewWorkflow(tsk)
 	wf.Run(&ti)
 }
+
+func TestRestartGrace(t *testing.T) {
+	// ignores SIGTERM so must be killed after the grace period
+	tsk := NewRestartTask("sh", "-c", "trap '' TERM; sleep 30 & wait")
+	tsk.GracePeriod = 200 * time.Millisecond
+	ti := goauto.TaskInfo{Tout: ioutil.Discard, Terr: ioutil.Discard, Verbose: goauto.Silent}
+
+	if err := tsk.Restart(&ti); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	p := tsk.proc
+	t0 := time.Now()
+	if err := tsk.Kill(&ti); err != nil {
+		t.Error(err)
+	}
+	if d := time.Since(t0); d < tsk.GracePeriod || d > 2*time.Second {
+		t.Errorf("Expected Kill after the grace period got %v", d)
+	}
+	select {
+	case <-p.done:
+	default:
+		t.Error("Expected process to have exited")
+	}
+}
+
+func TestRestartGroup(t *testing.T) {
+	// sleep is a child of sh and holds stdout open, Wait only returns once the whole group is gone
+	tsk := NewRestartTask("sh", "-c", "sleep 30; echo done")
+	tsk.GracePeriod = time.Second
+	ti := goauto.TaskInfo{Tout: new(bytes.Buffer), Terr: ioutil.Discard, Verbose: goauto.Silent}
+
+	if err := tsk.Restart(&ti); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	t0 := time.Now()
+	if err := tsk.Kill(&ti); err != nil {
+		t.Error(err)
+	}
+	if d := time.Since(t0); d > tsk.GracePeriod {
+		t.Errorf("Expected the group to stop on SIGTERM got %v", d)
+	}
+}
+
+func TestRestartOrphan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	alive := filepath.Join(dir, "alive")
+
+	// the shell exits at once leaving its child running in the group
+	tsk := NewRestartTask("sh", "-c", "(sleep 1; echo yes > "+alive+") >/dev/null 2>&1 &")
+	ti := goauto.TaskInfo{Tout: ioutil.Discard, Terr: ioutil.Discard, Verbose: goauto.Silent}
+	if err = tsk.Restart(&ti); err != nil {
+		t.Fatal(err)
+	}
+	<-tsk.proc.done
+	if err = tsk.Kill(&ti); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if _, err = os.Stat(alive); err == nil {
+		t.Error("Expected the group to be stopped after its leader exited")
+	}
+}
+
+func TestRestartAddr(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	if err = waitFree(addr, 100*time.Millisecond); err == nil {
+		t.Error("Expected an error for an address in use")
+	}
+	l.Close()
+	if err = waitFree(addr, 100*time.Millisecond); err != nil {
+		t.Error(err)
+	}
+}
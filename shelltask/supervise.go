@@ -0,0 +1,147 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package shelltask
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/dshills/goauto"
+)
+
+const (
+	defaultBackoff     = time.Second
+	maxBackoff         = 30 * time.Second
+	defaultMaxRestarts = 5
+	stableAfter        = 10 * time.Second // a process running this long is no longer crash looping
+	crashLines         = 10               // lines of output reported for a crash
+)
+
+// A ProcessState is the state of an application started by a RestartTask
+type ProcessState uint8
+
+// Process states
+const (
+	ProcessStopped  ProcessState = iota // Not started or killed
+	ProcessStarting                     // Started and waiting for the Ready checks
+	ProcessRunning
+	ProcessCrashed // Exited on its own and not restarted
+	ProcessBackoff // Crashed and waiting to be restarted
+)
+
+var stateNames = []string{"stopped", "starting", "running", "crashed", "backoff"}
+
+func (s ProcessState) String() string {
+	if int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return "unknown"
+}
+
+// State returns the state of the application
+func (r *RestartTask) State() ProcessState {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.state
+}
+
+func (r *RestartTask) setState(s ProcessState) {
+	r.stateMu.Lock()
+	r.state = s
+	r.stateMu.Unlock()
+}
+
+// exited handles the exit of p, ignoring processes that were killed
+func (r *RestartTask) exited(p *process, t *goauto.TaskInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.proc != p {
+		return
+	}
+	// r.proc is kept so Kill and Restart still stop anything the process left in its group
+	status := "exited"
+	if p.err != nil {
+		status = p.err.Error()
+	}
+	r.crashed(t, fmt.Errorf("Process %v crashed: %v\n%s", r.Cmd, status, lastLines(p.out.Bytes(), crashLines)))
+}
+
+// crashed reports err and schedules an automatic restart if supervised
+func (r *RestartTask) crashed(t *goauto.TaskInfo, err error) {
+	fmt.Fprintln(t.Terr, err)
+	if !r.Supervise {
+		r.setState(ProcessCrashed)
+		return
+	}
+	if time.Since(r.started) > stableAfter {
+		r.crashes = 0
+	}
+	r.crashes++
+	max := r.MaxRestarts
+	if max < 1 {
+		max = defaultMaxRestarts
+	}
+	if r.crashes > max {
+		r.setState(ProcessCrashed)
+		fmt.Fprintf(t.Terr, "Process %v is crash looping, giving up after %v restarts\n", r.Cmd, max)
+		return
+	}
+
+	delay := r.backoff(r.crashes)
+	r.setState(ProcessBackoff)
+	fmt.Fprintf(t.Terr, "Restarting %v in %v\n", r.Cmd, delay)
+	gen := r.gen
+	r.timer = time.AfterFunc(delay, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.gen != gen {
+			return
+		}
+		err := r.kill(t) // processes left in the group
+		if err == nil {
+			err = r.start(t)
+		}
+		if err != nil {
+			r.crashed(t, err)
+		}
+	})
+}
+
+// cancelRestart stops a pending automatic restart
+func (r *RestartTask) cancelRestart() {
+	r.gen++
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+// backoff returns the delay before automatic restart n
+func (r *RestartTask) backoff(n int) time.Duration {
+	d := r.Backoff
+	if d <= 0 {
+		d = defaultBackoff
+	}
+	for i := 1; i < n && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// lastLines returns the last n lines of b
+func lastLines(b []byte, n int) []byte {
+	b = bytes.TrimRight(b, "\n")
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] == '\n' {
+			if n--; n == 0 {
+				return b[i+1:]
+			}
+		}
+	}
+	return b
+}
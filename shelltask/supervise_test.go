@@ -0,0 +1,117 @@
+package shelltask
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dshills/goauto"
+)
+
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func waitState(tsk *RestartTask, s ProcessState) bool {
+	for i := 0; i < 100; i++ {
+		if tsk.State() == s {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func TestSuperviseCrashLoop(t *testing.T) {
+	errs := new(syncBuffer)
+	ti := goauto.TaskInfo{Tout: ioutil.Discard, Terr: errs, Verbose: goauto.Silent}
+	tsk := NewRestartTask("sh", "-c", "echo oops >&2; sleep 0.1; exit 2")
+	tsk.Supervise = true
+	tsk.Backoff = 50 * time.Millisecond
+	tsk.MaxRestarts = 2
+
+	if err := tsk.Restart(&ti); err != nil {
+		t.Fatal(err)
+	}
+	if tsk.State() != ProcessRunning {
+		t.Errorf("Expected running got %v", tsk.State())
+	}
+	if !waitState(tsk, ProcessCrashed) {
+		t.Fatalf("Expected crashed got %v", tsk.State())
+	}
+	out := errs.String()
+	for _, s := range []string{"crashed: exit status 2\noops", "Restarting sh in 50ms", "Restarting sh in 100ms", "giving up after 2 restarts"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in %q", s, out)
+		}
+	}
+	if n := strings.Count(out, "crashed:"); n != 3 {
+		t.Errorf("Expected 3 crashes got %v", n)
+	}
+}
+
+func TestSuperviseOff(t *testing.T) {
+	errs := new(syncBuffer)
+	ti := goauto.TaskInfo{Tout: ioutil.Discard, Terr: errs, Verbose: goauto.Silent}
+	tsk := NewRestartTask("sh", "-c", "exit 1")
+	if err := tsk.Restart(&ti); err != nil {
+		t.Fatal(err)
+	}
+	if !waitState(tsk, ProcessCrashed) {
+		t.Fatalf("Expected crashed got %v", tsk.State())
+	}
+	time.Sleep(100 * time.Millisecond)
+	if strings.Contains(errs.String(), "Restarting") {
+		t.Error("Expected no automatic restart")
+	}
+}
+
+func TestSuperviseKillBackoff(t *testing.T) {
+	ti := goauto.TaskInfo{Tout: ioutil.Discard, Terr: ioutil.Discard, Verbose: goauto.Silent}
+	tsk := NewRestartTask("sh", "-c", "exit 1")
+	tsk.Supervise = true
+	tsk.Backoff = 300 * time.Millisecond
+	if err := tsk.Restart(&ti); err != nil {
+		t.Fatal(err)
+	}
+	if !waitState(tsk, ProcessBackoff) {
+		t.Fatalf("Expected backoff got %v", tsk.State())
+	}
+	tsk.Kill(&ti)
+	time.Sleep(500 * time.Millisecond)
+	if tsk.State() != ProcessStopped {
+		t.Errorf("Expected the restart to be cancelled got %v", tsk.State())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tsk := NewRestartTask("sh")
+	exp := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	for i, d := range exp {
+		if b := tsk.backoff(i + 1); b != d {
+			t.Errorf("Expected %v got %v", d, b)
+		}
+	}
+	if b := tsk.backoff(20); b != maxBackoff {
+		t.Errorf("Expected %v got %v", maxBackoff, b)
+	}
+	if s := string(lastLines([]byte("a\nb\nc\n"), 2)); s != "b\nc" {
+		t.Errorf("Expected last 2 lines got %q", s)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultGrace = 10 * time.Second
+
+func (p *Pipeline) begin() {
+	if p != nil {
+		p.running.Add(1)
+	}
+}
+
+func (p *Pipeline) end() {
+	if p != nil {
+		p.running.Done()
+	}
+}
+
+// handleSignals stops the Pipeline on SIGINT or SIGTERM
+// A second signal is not caught so it will end the process
+// returns a function that stops handling signals
+func (p *Pipeline) handleSignals() (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		s, ok := <-sigs
+		if !ok {
+			return
+		}
+		signal.Stop(sigs)
+		fmt.Fprintf(p.Wout, "> Received %v, stopping Pipeline %v\n", s, p.Name)
+		p.Stop()
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(sigs)
+	}
+}
+
+// shutdown waits for the Workflow goroutine and running Workflows, stops the Stoppers in the Workflows and the managed Services
+func (p *Pipeline) shutdown() (err error) {
+	grace := p.GracePeriod
+	if grace <= 0 {
+		grace = defaultGrace
+	}
+	done := make(chan struct{})
+	go func() {
+		// an Event taken before the quit may not have started its Workflows yet
+		p.dispatching.Wait()
+		p.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(grace):
+		err = fmt.Errorf("Pipeline %v stopped with Workflows still running after %v", p.Name, grace)
+		fmt.Fprintln(p.Werr, err)
+	}
+	p.closePool()
+
+	for _, s := range p.stoppers() {
+		if serr := s.Stop(); serr != nil {
+			fmt.Fprintln(p.Werr, serr)
+			if err == nil {
+				err = serr
+			}
+		}
+	}
+	if serr := p.stopServices(p.services); err == nil {
+		err = serr
+	}
+	if p.Verbose {
+		fmt.Fprintln(p.Wout, "> Pipeline", p.Name, "shut down")
+	}
+	return
+}
+
+// stoppers returns the Stopper tasks in the Workflows and the Workflows chained to them
+func (p *Pipeline) stoppers() (ss []Stopper) {
+	seen := make(map[*Workflow]bool)
+	var walk func(w Workflower)
+	walk = func(w Workflower) {
+		cw, ok := w.(*Workflow)
+		if !ok || seen[cw] {
+			return
+		}
+		seen[cw] = true
+		for _, t := range cw.Tasks {
+			for t != nil {
+				if s, ok := t.(Stopper); ok {
+					ss = append(ss, s)
+					break
+				}
+				w, ok := t.(wrapper)
+				if !ok {
+					break
+				}
+				t = w.unwrap()
+			}
+		}
+		for _, c := range cw.chains {
+			if c.join != nil {
+				walk(c.join.next)
+			} else {
+				walk(c.next)
+			}
+		}
+	}
+	for _, wf := range p.Workflows {
+		walk(wf)
+	}
+	return
+}
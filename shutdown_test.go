@@ -0,0 +1,109 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type stopTask struct {
+	noTask
+	stopped *int
+}
+
+func (t stopTask) Stop() error {
+	*t.stopped++
+	return nil
+}
+
+func TestPipelineShutdown(t *testing.T) {
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Wout, p.Werr = ioutil.Discard, ioutil.Discard
+	p.GracePeriod = 20 * time.Millisecond
+
+	stopped := 0
+	st := stopTask{stopped: &stopped}
+	slow := NewWorkflow(NewResourceTask(st, "server"), NewTask(Identity, func(*TaskInfo) error {
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	}))
+	slow.Concurrent = true
+	p.Add(slow, NewWorkflow(st))
+
+	if err := p.shutdown(); err != nil {
+		t.Error(err)
+	}
+	if stopped != 2 {
+		t.Errorf("Expected 2 stops got %v", stopped)
+	}
+
+	slow.Run(&TaskInfo{Src: "a.go", Tout: ioutil.Discard, Terr: ioutil.Discard, pipeline: p})
+	if err := p.shutdown(); err == nil {
+		t.Errorf("Expected error for Workflow running past the grace period")
+	}
+	if stopped != 4 {
+		t.Errorf("Expected 4 stops got %v", stopped)
+	}
+
+	p.GracePeriod = time.Second
+	if err := p.shutdown(); err != nil {
+		t.Errorf("Expected running Workflow to finish within the grace period, %v", err)
+	}
+}
+
+func TestPipelineShutdownWaitsForDispatch(t *testing.T) {
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Wout, p.Werr = ioutil.Discard, ioutil.Discard
+	p.GracePeriod = time.Second
+
+	finished := false
+	wf := NewWorkflow(NewTask(Identity, func(*TaskInfo) error {
+		time.Sleep(50 * time.Millisecond)
+		finished = true
+		return nil
+	}))
+	wf.WatchPattern(`\.go$`)
+	p.Add(wf)
+
+	p.control = make(chan func())
+	p.done = make(chan struct{})
+	in := p.queryWorkflow()
+	in <- &Event{Path: "a.go", Op: Write}
+	close(in)
+	if err := p.shutdown(); err != nil {
+		t.Error(err)
+	}
+	if !finished {
+		t.Errorf("Expected shutdown to wait for the Workflow started by the last Event")
+	}
+}
+
+func TestPipelineShutdownChained(t *testing.T) {
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Wout, p.Werr = ioutil.Discard, ioutil.Discard
+
+	stopped := 0
+	build, test := NewWorkflow(), NewWorkflow()
+	deploy := NewWorkflow(NewFreshTask(stopTask{stopped: &stopped}))
+	report := NewWorkflow(stopTask{stopped: &stopped})
+	if err := build.Chain(OnSuccess, deploy); err != nil {
+		t.Fatal(err)
+	}
+	if err := deploy.Chain(OnAlways, report); err != nil {
+		t.Fatal(err)
+	}
+	if err := ChainAll(OnSuccess, report, build, test); err != nil {
+		t.Fatal(err)
+	}
+	p.Add(build, test)
+
+	if err := p.shutdown(); err != nil {
+		t.Error(err)
+	}
+	if stopped != 2 {
+		t.Errorf("Expected the 2 chained Stoppers stopped once got %v", stopped)
+	}
+}
@@ -16,6 +16,8 @@ type TaskInfo struct {
 	Tout, Terr io.Writer    // Writers to write output and errors
 	Collect    []string     // List of file names processed by a Workflow
 	Verbose    bool         // output debug info
+	pipeline   *Pipeline    // Pipeline running the Workflow if any
+	pass       *pass        // Tracks the Workflow runs of a Pipeline pass if any
 }
 
 // A Runner represents the function needed to satisfy a Tasker interface
@@ -32,6 +34,15 @@ type Tasker interface {
 	Run(info *TaskInfo) (err error)
 }
 
+// A Stopper is a Tasker that leaves something running after Run returns, such as a server process
+// When a Pipeline shuts down it calls Stop for each Stopper in its Workflows and the Workflows chained to them
+// Tasks wrapped by the goauto wrappers such as NewResourceTask and NewFreshTask are found, any other Task
+// that runs Stoppers must implement Stopper itself and pass Stop on, as webtask.NewProxyTask does
+// Stop may be called more than once
+type Stopper interface {
+	Stop() error
+}
+
 type emptyTask struct{}
 
 func (t *emptyTask) Run(i *TaskInfo) error {
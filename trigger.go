@@ -0,0 +1,387 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Trigger represents a source of Events other than a file system Watcher
+// such as a timer or a signal
+// An Event with a blank Path stands for every file watched by the Pipeline
+type Trigger interface {
+	Start() (<-chan ESlice, error)
+	Stop() error
+}
+
+// pathEvents returns Write Events for paths or an Event for every file if there are no paths
+func pathEvents(paths []string) ESlice {
+	if len(paths) == 0 {
+		return ESlice{&Event{Op: Write}}
+	}
+	es := make(ESlice, 0, len(paths))
+	for _, p := range paths {
+		if ap, err := AbsPath(p); err == nil {
+			p = ap
+		}
+		es = append(es, &Event{Path: p, Op: Write})
+	}
+	return es
+}
+
+// trigger is the shared plumbing for the built in Triggers
+type trigger struct {
+	done chan struct{}
+	once sync.Once
+	werr io.Writer // errors after Start, set to the Pipeline Werr
+}
+
+// setErr sets the writer for errors that happen after Start
+func (t *trigger) setErr(w io.Writer) {
+	t.werr = w
+}
+
+// report writes an error that happened after Start
+func (t *trigger) report(err error) {
+	w := t.werr
+	if w == nil {
+		w = os.Stderr
+	}
+	fmt.Fprintln(w, err)
+}
+
+func (t *trigger) start() chan ESlice {
+	t.done = make(chan struct{})
+	t.once = sync.Once{}
+	return make(chan ESlice)
+}
+
+// send delivers es unless the Trigger has been stopped
+func (t *trigger) send(c chan<- ESlice, es ESlice) bool {
+	select {
+	case c <- es:
+		return true
+	case <-t.done:
+		return false
+	}
+}
+
+func (t *trigger) Stop() error {
+	if t.done == nil {
+		return errors.New("Trigger not started")
+	}
+	t.once.Do(func() { close(t.done) })
+	return nil
+}
+
+type timeTrigger struct {
+	trigger
+	paths []string
+	next  func(time.Time) time.Time
+}
+
+// NewIntervalTrigger returns a Trigger that fires every d for paths
+// Without paths every file watched by the Pipeline is used
+func NewIntervalTrigger(d time.Duration, paths ...string) (Trigger, error) {
+	if d <= 0 {
+		return nil, fmt.Errorf("Interval %v must be positive", d)
+	}
+	return &timeTrigger{paths: paths, next: func(t time.Time) time.Time { return t.Add(d) }}, nil
+}
+
+// NewCronTrigger returns a Trigger that fires for paths on a cron style schedule
+// The schedule has five fields, minute hour day-of-month month day-of-week,
+// each a *, number, range or list with optional steps i.e. "*/15 9-17 * * 1-5"
+// @hourly, @daily and @weekly are also accepted
+// Without paths every file watched by the Pipeline is used
+func NewCronTrigger(schedule string, paths ...string) (Trigger, error) {
+	s, err := parseCron(schedule)
+	if err != nil {
+		return nil, err
+	}
+	return &timeTrigger{paths: paths, next: s.next}, nil
+}
+
+func (t *timeTrigger) Start() (<-chan ESlice, error) {
+	c := t.start()
+	go func() {
+		for {
+			now := time.Now()
+			timer := time.NewTimer(t.next(now).Sub(now))
+			select {
+			case <-timer.C:
+				if !t.send(c, pathEvents(t.paths)) {
+					return
+				}
+			case <-t.done:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+type signalTrigger struct {
+	trigger
+	paths []string
+	sigs  []os.Signal
+	sigc  chan os.Signal
+}
+
+// NewSignalTrigger returns a Trigger that fires for paths when the process receives sig
+// i.e. NewSignalTrigger(syscall.SIGUSR1) reruns everything on kill -USR1
+// Without paths every file watched by the Pipeline is used
+func NewSignalTrigger(sig os.Signal, paths ...string) Trigger {
+	return &signalTrigger{paths: paths, sigs: []os.Signal{sig}}
+}
+
+func (t *signalTrigger) Start() (<-chan ESlice, error) {
+	c := t.start()
+	t.sigc = make(chan os.Signal, 1)
+	signal.Notify(t.sigc, t.sigs...)
+	go func() {
+		for {
+			select {
+			case <-t.sigc:
+				if !t.send(c, pathEvents(t.paths)) {
+					return
+				}
+			case <-t.done:
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+func (t *signalTrigger) Stop() error {
+	if t.sigc != nil {
+		signal.Stop(t.sigc)
+	}
+	return t.trigger.Stop()
+}
+
+type lineTrigger struct {
+	trigger
+	open func() (io.ReadCloser, error)
+	mu   sync.Mutex
+	r    io.ReadCloser // closed by Stop to end a blocked read
+}
+
+// NewReaderTrigger returns a Trigger that fires for each line read from r
+// Each line is a file path, a blank line stands for every file watched by the Pipeline
+func NewReaderTrigger(r io.Reader) Trigger {
+	used := false
+	return &lineTrigger{open: func() (io.ReadCloser, error) {
+		if used {
+			return nil, io.EOF
+		}
+		used = true
+		return ioutil.NopCloser(r), nil
+	}}
+}
+
+// NewStdinTrigger returns a Trigger that fires for each line typed on stdin (See NewReaderTrigger)
+func NewStdinTrigger() Trigger {
+	return NewReaderTrigger(os.Stdin)
+}
+
+// NewPipeTrigger returns a Trigger that fires for each line written to the named pipe name (See NewReaderTrigger)
+// The pipe must already exist, i.e. mkfifo /tmp/goauto
+// It is opened for reading and writing so Start does not wait for a writer and
+// the Trigger keeps reading after a writer closes the pipe
+func NewPipeTrigger(name string) Trigger {
+	return &lineTrigger{open: func() (io.ReadCloser, error) {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		if fi.Mode()&os.ModeNamedPipe == 0 {
+			return nil, fmt.Errorf("%v is not a named pipe", name)
+		}
+		return os.OpenFile(name, os.O_RDWR, 0)
+	}}
+}
+
+func (t *lineTrigger) Start() (<-chan ESlice, error) {
+	r, err := t.open()
+	if err != nil {
+		return nil, err
+	}
+	c := t.start()
+	t.setReader(r)
+	go func() {
+		for {
+			s := bufio.NewScanner(r)
+			for s.Scan() {
+				var paths []string
+				if l := strings.TrimSpace(s.Text()); l != "" {
+					paths = append(paths, l)
+				}
+				if !t.send(c, pathEvents(paths)) {
+					r.Close()
+					return
+				}
+			}
+			r.Close()
+			select {
+			case <-t.done:
+				return
+			default:
+			}
+			if err := s.Err(); err != nil {
+				t.report(err)
+			}
+			var err error
+			if r, err = t.open(); err != nil {
+				if err != io.EOF {
+					t.report(err)
+				}
+				return
+			}
+			if !t.setReader(r) {
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+// setReader records r so Stop can close it
+// returns false and closes r if the Trigger was stopped
+func (t *lineTrigger) setReader(r io.ReadCloser) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	select {
+	case <-t.done:
+		r.Close()
+		return false
+	default:
+	}
+	t.r = r
+	return true
+}
+
+func (t *lineTrigger) Stop() error {
+	err := t.trigger.Stop()
+	t.mu.Lock()
+	if t.r != nil {
+		t.r.Close()
+	}
+	t.mu.Unlock()
+	return err
+}
+
+// cron is a parsed cron schedule, each field is a bit set of allowed values
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	anyDom, anyDow                bool
+}
+
+var cronAliases = map[string]string{
+	"@hourly": "0 * * * *",
+	"@daily":  "0 0 * * *",
+	"@weekly": "0 0 * * 0",
+}
+
+func parseCron(spec string) (*cron, error) {
+	if a, ok := cronAliases[spec]; ok {
+		spec = a
+	}
+	f := strings.Fields(spec)
+	if len(f) != 5 {
+		return nil, fmt.Errorf("Cron schedule %q needs 5 fields", spec)
+	}
+	c := new(cron)
+	var err error
+	bounds := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := []*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	for i := range f {
+		if *sets[i], err = parseCronField(f[i], bounds[i][0], bounds[i][1]); err != nil {
+			return nil, fmt.Errorf("Cron schedule %q: %v", spec, err)
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 // 7 is also Sunday
+	}
+	c.anyDom, c.anyDow = f[2] == "*", f[4] == "*"
+	return c, nil
+}
+
+func parseCronField(field string, min, max int) (set uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step, stepped := 1, false
+		if i := strings.Index(part, "/"); i >= 0 {
+			stepped = true
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			part = part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			r := strings.SplitN(part, "-", 2)
+			if lo, err = strconv.Atoi(r[0]); err != nil {
+				return 0, fmt.Errorf("bad value %q", part)
+			}
+			hi = lo
+			if stepped {
+				hi = max // 5/15 is 5-max/15
+			}
+			if len(r) == 2 {
+				if hi, err = strconv.Atoi(r[1]); err != nil {
+					return 0, fmt.Errorf("bad range %q", part)
+				}
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %v-%v", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+func (c *cron) dayMatch(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.anyDom || c.anyDow {
+		return dom && dow
+	}
+	return dom || dow // cron matches either when both are restricted
+}
+
+// next returns the first time after t that matches the schedule
+func (c *cron) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatch(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return limit // impossible schedule such as 30 February
+}
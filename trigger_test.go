@@ -0,0 +1,181 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCron(t *testing.T) {
+	start := time.Date(2015, time.April, 14, 10, 7, 30, 0, time.UTC) // a Tuesday
+	tests := []struct {
+		spec, expect string
+	}{
+		{"* * * * *", "2015-04-14 10:08"},
+		{"*/15 * * * *", "2015-04-14 10:15"},
+		{"5/20 * * * *", "2015-04-14 10:25"},
+		{"0 9-17 * * 1-5", "2015-04-14 11:00"},
+		{"30 2 * * 0", "2015-04-19 02:30"},
+		{"0 0 1 5 *", "2015-05-01 00:00"},
+		{"0 0 13 * 5", "2015-04-17 00:00"},
+		{"@daily", "2015-04-15 00:00"},
+		{"0 0 * * 7", "2015-04-19 00:00"},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.spec)
+		if err != nil {
+			t.Errorf("%v: %v", tt.spec, err)
+			continue
+		}
+		if n := c.next(start).Format("2006-01-02 15:04"); n != tt.expect {
+			t.Errorf("%v: expected %v got %v", tt.spec, tt.expect, n)
+		}
+	}
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := NewCronTrigger(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestIntervalTrigger(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		if _, err := NewIntervalTrigger(d); err == nil {
+			t.Errorf("Expected error for %v", d)
+		}
+	}
+	tr, err := NewIntervalTrigger(10*time.Millisecond, "/bogus/a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := tr.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		es := <-c
+		if len(es) != 1 || es[0].Path != "/bogus/a.go" || es[0].Op != Write {
+			t.Errorf("Expected Write for /bogus/a.go got %v", es)
+		}
+	}
+	if err = tr.Stop(); err != nil {
+		t.Error(err)
+	}
+	if err = tr.Stop(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReaderTrigger(t *testing.T) {
+	tr := NewReaderTrigger(strings.NewReader("/bogus/a.go\n\n"))
+	c, err := tr.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if es := <-c; len(es) != 1 || es[0].Path != "/bogus/a.go" {
+		t.Errorf("Expected /bogus/a.go got %v", es)
+	}
+	if es := <-c; len(es) != 1 || es[0].Path != "" {
+		t.Errorf("Expected Event for every file got %v", es)
+	}
+	tr.Stop()
+}
+
+func TestPipeTrigger(t *testing.T) {
+	if _, err := NewPipeTrigger("/bogus/pipe").Start(); err == nil {
+		t.Error("Expected error for a missing pipe")
+	}
+	f, err := ioutil.TempFile("", "goauto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if _, err = NewPipeTrigger(f.Name()).Start(); err == nil {
+		t.Error("Expected error for a file that is not a pipe")
+	}
+
+	// errors reopening are reported
+	opens := 0
+	tr := &lineTrigger{open: func() (io.ReadCloser, error) {
+		if opens++; opens > 1 {
+			return nil, errors.New("pipe removed")
+		}
+		return ioutil.NopCloser(strings.NewReader("/bogus/a.go\n")), nil
+	}}
+	werr := make(chanWriter, 1)
+	tr.setErr(werr)
+	c, err := tr.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if es := <-c; len(es) != 1 || es[0].Path != "/bogus/a.go" {
+		t.Errorf("Expected /bogus/a.go got %v", es)
+	}
+	select {
+	case s := <-werr:
+		if s != "pipe removed\n" {
+			t.Errorf("Expected reopen error reported got %q", s)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected reopen error reported")
+	}
+	tr.Stop()
+}
+
+// chanWriter sends each write on the channel
+type chanWriter chan string
+
+func (w chanWriter) Write(b []byte) (int, error) {
+	w <- string(b)
+	return len(b), nil
+}
+
+func TestPipelineExpand(t *testing.T) {
+	p := NewPipeline("Test Pipeline", Silent)
+	if _, err := p.Watch("testing"); err != nil {
+		t.Fatal(err)
+	}
+	es := p.expand(ESlice{&Event{Op: Write}, &Event{Path: "/bogus", Op: Create}})
+	if len(es) != 3 || es[2].Path != "/bogus" {
+		t.Errorf("Expected the 2 test files and /bogus got %v", es)
+	}
+}
+
+func TestTriggerEmptyWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewPipeline("Test Pipeline", Silent)
+	p.Wout, p.Werr, p.Signals = ioutil.Discard, ioutil.Discard, false
+	p.Watch(dir)
+	tr, err := NewIntervalTrigger(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.AddTrigger(tr)
+
+	done := make(chan error, 1)
+	go func() { done <- p.Start() }()
+	select {
+	case <-done:
+		t.Fatal("Expected the Pipeline to keep running when a Trigger expands to no files")
+	case <-time.After(200 * time.Millisecond):
+	}
+	p.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected Start to return after Stop")
+	}
+}
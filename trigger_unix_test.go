@@ -0,0 +1,65 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+// +build !windows
+
+package goauto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPipeTriggerFifo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fifo := filepath.Join(dir, "fifo")
+	if err = syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := NewPipeTrigger(fifo)
+	c, err := tr.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the Trigger keeps reading after each writer closes the pipe
+	for _, p := range []string{"/bogus/a.go", "/bogus/b.go"} {
+		w, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteString(p + "\n")
+		w.Close()
+		select {
+		case es := <-c:
+			if len(es) != 1 || es[0].Path != p {
+				t.Errorf("Expected %v got %v", p, es)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected an Event for %v", p)
+		}
+	}
+
+	// Stop returns with no writer connected and releases the pipe
+	stopped := make(chan error, 1)
+	go func() { stopped <- tr.Stop() }()
+	select {
+	case err = <-stopped:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Stop to return while no writer is connected")
+	}
+	if w, err := os.OpenFile(fifo, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+		w.Close()
+		t.Error("Expected the pipe to have no reader after Stop")
+	}
+}
@@ -5,6 +5,7 @@ package goauto
 
 import (
 	"io"
+	"strings"
 	"time"
 )
 
@@ -22,6 +23,20 @@ const (
 	Chmod
 )
 
+// String returns the operations separated by |
+func (op Op) String() string {
+	var s []string
+	for i, n := range []string{"Create", "Write", "Remove", "Rename", "Chmod"} {
+		if op&(1<<uint(i)) != 0 {
+			s = append(s, n)
+		}
+	}
+	if len(s) == 0 {
+		return "None"
+	}
+	return strings.Join(s, "|")
+}
+
 // Event represents a file system notification
 type Event struct {
 	Path string
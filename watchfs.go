@@ -38,7 +38,7 @@ func (w *watchFS) Start(latency time.Duration, paths []string) (<-chan ESlice, e
 	}
 	w.watcher = watcher
 
-	go w.bufferEvents(c, latency)
+	go w.bufferEvents(watcher, c, latency)
 
 	for _, d := range paths {
 		if err := w.watcher.Add(d); err != nil {
@@ -56,7 +56,8 @@ func (w *watchFS) Start(latency time.Duration, paths []string) (<-chan ESlice, e
 // bufferEvents watches for file events and batches them up based on a timer
 // if the event distributer is busy it just keeps batching up events
 // **Thanks to github.com/egonelbre for the suggestions and examples for batch events
-func (w *watchFS) bufferEvents(send chan<- ESlice, l time.Duration) {
+// watcher is passed in as Stop clears w.watcher
+func (w *watchFS) bufferEvents(watcher *fsnotify.Watcher, send chan<- ESlice, l time.Duration) {
 	defer close(send)
 
 	tick := time.Tick(l)
@@ -66,9 +67,9 @@ func (w *watchFS) bufferEvents(send chan<- ESlice, l time.Duration) {
 	for {
 		select {
 		// buffer the events
-		case e := <-w.watcher.Events:
+		case e := <-watcher.Events:
 			buf = append(buf, &Event{Path: e.Name, Op: Op(e.Op)})
-		case err := <-w.watcher.Errors:
+		case err := <-watcher.Errors:
 			if w.out != nil {
 				fmt.Fprintln(w.out, err)
 			}
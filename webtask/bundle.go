@@ -0,0 +1,268 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/dshills/goauto"
+)
+
+// //= include lib.js, //= require lib.js or /*= include base.css */ on a line of its own
+var includeDirective = regexp.MustCompile(`^\s*(?://|/\*)=\s*(?:include|require)\s+["']?([^"'\s*]+)["']?\s*(?:\*/)?\s*$`)
+
+// A Bundle concatenates Files into Target
+// Files are paths or glob patterns, glob matches are added in name order
+// A line containing only an include directive, //= include other.js or /*= include other.css */,
+// is replaced by the named file relative to the including file
+// Each file is added once, the first time it is listed or included
+type Bundle struct {
+	Target    string
+	Files     []string
+	SourceMap bool // Write Target.map mapping each line to the file it came from
+}
+
+// NewBundle returns a Bundle of files written to target
+func NewBundle(target string, files ...string) *Bundle {
+	return &Bundle{Target: target, Files: files}
+}
+
+// Members returns the files in the Bundle including the files they include, each after the files it includes
+// Files with only include directives are members
+// Files that can not be read are members, the walk goes on past them and the first error is returned
+func (b *Bundle) Members() ([]string, error) {
+	var files []string
+	err := b.walk(true, func(string, []byte, int, [][]byte) error {
+		return nil
+	}, func(f string, _ []byte) {
+		files = append(files, f)
+	})
+	return files, err
+}
+
+// Contains reports whether fpath is part of the Bundle
+func (b *Bundle) Contains(fpath string) bool {
+	ok, _ := b.contains(fpath)
+	return ok
+}
+
+// contains reports whether fpath is part of the Bundle and the error reading its members if any
+func (b *Bundle) contains(fpath string) (bool, error) {
+	fpath, err := filepath.Abs(fpath)
+	if err != nil {
+		return false, err
+	}
+	files, err := b.Members()
+	for _, f := range files {
+		if f == fpath {
+			return true, err
+		}
+	}
+	return false, err
+}
+
+// Build writes the Bundle to Target
+func (b *Bundle) Build() error {
+	e := new(emitter)
+	if b.SourceMap && mapComment(b.Target) != "" {
+		e.sm = new(sourceMap)
+	}
+	js := filepath.Ext(b.Target) == ".js" || filepath.Ext(b.Target) == ".mjs"
+	srcs := make(map[string]int)
+	err := b.walk(false, func(f string, src []byte, start int, lines [][]byte) error {
+		if e.sm != nil {
+			idx, ok := srcs[f]
+			if !ok {
+				idx = e.sm.addSource(mapSource(b.Target, f))
+				srcs[f] = idx
+			}
+			e.source(src, idx)
+		}
+		off := start
+		for _, l := range lines {
+			e.mark(off)
+			e.write(l)
+			off += len(l)
+		}
+		return nil
+	}, func(f string, src []byte) {
+		// end of a file, keep the next one on its own line and statement
+		if e.last() != 0 && e.last() != '\n' {
+			e.writeByte('\n')
+		}
+		if t := bytes.TrimSpace(src); js && len(t) > 0 && t[len(t)-1] != ';' {
+			e.write([]byte(";\n"))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return e.writeFile(b.Target)
+}
+
+// walk calls part with each run of lines between include directives in Bundle order
+// and end, if given, after the last line of each file
+// With keepGoing a file that can not be read or included ends with no src and the walk goes on,
+// the first error is returned when it is done
+func (b *Bundle) walk(keepGoing bool, part func(f string, src []byte, start int, lines [][]byte) error, end ...func(f string, src []byte)) error {
+	seen := make(map[string]bool)
+	var first error
+	fail := func(err error) error {
+		if !keepGoing {
+			return err
+		}
+		if first == nil {
+			first = err
+		}
+		return nil
+	}
+	var add func(f string, stack []string) error
+	add = func(f string, stack []string) error {
+		if seen[f] {
+			return nil
+		}
+		seen[f] = true
+		src, err := ioutil.ReadFile(f)
+		if err != nil {
+			if err = fail(err); err == nil {
+				for _, fn := range end {
+					fn(f, nil)
+				}
+			}
+			return err
+		}
+		var lines [][]byte
+		start, off := 0, 0
+		flush := func() error {
+			if len(lines) == 0 {
+				return nil
+			}
+			err := part(f, src, start, lines)
+			lines = nil
+			return err
+		}
+		for _, l := range bytes.SplitAfter(src, []byte("\n")) {
+			if len(l) == 0 {
+				continue
+			}
+			if m := includeDirective.FindSubmatch(l); m != nil {
+				if err = flush(); err != nil {
+					return err
+				}
+				inc := filepath.Join(filepath.Dir(f), filepath.FromSlash(string(m[1])))
+				cycle := false
+				for _, s := range stack {
+					if s == inc {
+						cycle = true
+					}
+				}
+				if cycle {
+					if err = fail(fmt.Errorf("%v includes itself through %v", inc, f)); err != nil {
+						return err
+					}
+				} else if err = add(inc, append(stack, f)); err != nil {
+					return fmt.Errorf("%v: %v", f, err)
+				}
+				off += len(l)
+				start = off
+				continue
+			}
+			if len(lines) == 0 {
+				start = off
+			}
+			lines = append(lines, l)
+			off += len(l)
+		}
+		if err = flush(); err != nil {
+			return err
+		}
+		for _, fn := range end {
+			fn(f, src)
+		}
+		return nil
+	}
+
+	target, err := filepath.Abs(b.Target)
+	if err != nil {
+		return err
+	}
+	seen[target] = true // a glob may match the Target
+	for _, pattern := range b.Files {
+		files, err := filepath.Glob(pattern)
+		if err == nil && len(files) == 0 {
+			err = fmt.Errorf("Bundle %v: no files match %v", b.Target, pattern)
+		}
+		if err != nil {
+			if err = fail(err); err != nil {
+				return err
+			}
+			continue
+		}
+		sort.Strings(files)
+		for _, f := range files {
+			if f, err = filepath.Abs(f); err != nil {
+				return err
+			}
+			if err = add(f, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return first
+}
+
+type bundleTask struct {
+	bundles []*Bundle
+}
+
+// Affected returns the bundles that fpath is part of
+func Affected(fpath string, bundles ...*Bundle) []*Bundle {
+	var bs []*Bundle
+	for _, b := range bundles {
+		if b.Contains(fpath) {
+			bs = append(bs, b)
+		}
+	}
+	return bs
+}
+
+// NewBundleTask returns a Task that builds the bundles TaskInfo.Src is part of
+// TaskInfo.Target is set to the last Bundle built, the others are added to TaskInfo.Collect
+// If TaskInfo.Src is in no Bundle TaskInfo.Target is set to TaskInfo.Src
+// Members that can not be read are written to TaskInfo.Terr for the Bundles not built
+func NewBundleTask(bundles ...*Bundle) goauto.Tasker {
+	return &bundleTask{bundles: bundles}
+}
+
+func (bt *bundleTask) Run(info *goauto.TaskInfo) (err error) {
+	info.Buf.Reset()
+	info.Target = info.Src
+	var affected []*Bundle
+	for _, b := range bt.bundles {
+		ok, cerr := b.contains(info.Src)
+		if ok {
+			affected = append(affected, b)
+		} else if cerr != nil {
+			fmt.Fprintf(info.Terr, "Bundle %v: %v\n", b.Target, cerr)
+		}
+	}
+	for i, b := range affected {
+		if err = b.Build(); err != nil {
+			return
+		}
+		if info.Verbose {
+			fmt.Fprintf(info.Tout, "<< Bundled %v\n", b.Target)
+		}
+		if i < len(affected)-1 {
+			info.Collect = append(info.Collect, b.Target)
+		}
+		info.Target = b.Target
+	}
+	return
+}
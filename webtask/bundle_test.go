@@ -0,0 +1,172 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dshills/goauto"
+)
+
+func TestBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"js/a.js":         "var a = {}",
+		"js/b.js":         "//= require lib/util.js\nb(a);\n",
+		"js/lib/util.js":  "function b(x) {\n  return x\n}",
+		"js/lib/extra.js": "//= include util.js\nextra()\n",
+		"css/base.css":    "body{}\n",
+		"css/app.css":     "/*= include base.css */\n.app{}\n",
+	})
+	p := func(n string) string { return filepath.Join(dir, filepath.FromSlash(n)) }
+
+	js := NewBundle(p("js/bundle.js"), p("js/*.js"), p("js/lib/extra.js"))
+	js.SourceMap = true
+	css := NewBundle(p("css/bundle.css"), p("css/app.css"))
+	members, err := js.Members()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{p("js/a.js"), p("js/lib/util.js"), p("js/b.js"), p("js/lib/extra.js")}; !reflect.DeepEqual(members, exp) {
+		t.Errorf("Expected %v got %v", exp, members)
+	}
+
+	if err = js.Build(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(js.Target)
+	exp := "var a = {}\n;\nfunction b(x) {\n  return x\n}\n;\nb(a);\nextra()\n;\n\n//# sourceMappingURL=bundle.js.map\n"
+	if string(b) != exp {
+		t.Errorf("Expected %q got %q", exp, b)
+	}
+	var sm struct {
+		Sources  []string
+		Mappings string
+	}
+	b, _ = ioutil.ReadFile(js.Target + ".map")
+	if err = json.Unmarshal(b, &sm); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"a.js", "lib/util.js", "b.js", "lib/extra.js"}; !reflect.DeepEqual(sm.Sources, exp) {
+		t.Errorf("Expected sources %v got %v", exp, sm.Sources)
+	}
+	// b(a) is on generated line 6 and is line 1 of b.js
+	found := false
+	for _, s := range decodeMappings(sm.Mappings) {
+		if s == (segment{6, 0, 2, 1, 0}) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected b(a) mapped to b.js got %v", decodeMappings(sm.Mappings))
+	}
+
+	// rebuilding does not include the bundle itself
+	if err = js.Build(); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := js.Members(); len(m) != 4 {
+		t.Errorf("Expected the Target skipped got %v", m)
+	}
+
+	if bs := Affected(p("js/lib/util.js"), js, css); len(bs) != 1 || bs[0] != js {
+		t.Errorf("Expected js bundle affected by util.js got %v", bs)
+	}
+	ti := &goauto.TaskInfo{Src: p("css/base.css"), Tout: ioutil.Discard, Terr: ioutil.Discard}
+	if err = NewBundleTask(js, css).Run(ti); err != nil {
+		t.Fatal(err)
+	}
+	if ti.Target != css.Target {
+		t.Errorf("Expected %v got %v", css.Target, ti.Target)
+	}
+	if b, _ = ioutil.ReadFile(css.Target); string(b) != "body{}\n.app{}\n" {
+		t.Errorf("Unexpected css bundle %q", b)
+	}
+
+	writeFiles(t, dir, map[string]string{"js/lib/util.js": "//= include extra.js\n"})
+	if err = js.Build(); err == nil || !strings.Contains(err.Error(), "includes itself") {
+		t.Errorf("Expected include cycle error got %v", err)
+	}
+}
+
+func TestBundleIncludeOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"js/index.js": "//= include a.js\n//= include b.js\n",
+		"js/a.js":     "a()\n",
+		"js/b.js":     "//= include a.js\nb()\n",
+		"js/other.js": "other()\n",
+	})
+	p := func(n string) string { return filepath.Join(dir, filepath.FromSlash(n)) }
+	js := NewBundle(p("out/app.js"), p("js/index.js"))
+	members, err := js.Members()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{p("js/a.js"), p("js/b.js"), p("js/index.js")}; !reflect.DeepEqual(members, exp) {
+		t.Errorf("Expected %v got %v", exp, members)
+	}
+	if !js.Contains(p("js/index.js")) || js.Contains(p("js/other.js")) {
+		t.Error("Expected the entry file to be a member")
+	}
+	// editing the entry file rebuilds the bundle
+	os.MkdirAll(p("out"), 0755)
+	ti := &goauto.TaskInfo{Src: p("js/index.js"), Tout: ioutil.Discard, Terr: ioutil.Discard}
+	if err = NewBundleTask(js).Run(ti); err != nil {
+		t.Fatal(err)
+	}
+	if ti.Target != js.Target {
+		t.Errorf("Expected %v got %v", js.Target, ti.Target)
+	}
+}
+
+func TestBundleMissingMember(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"js/index.js": "//= include gone.js\n//= include a.js\n",
+		"js/a.js":     "a()\n",
+		"js/b.js":     "b()\n",
+	})
+	p := func(n string) string { return filepath.Join(dir, filepath.FromSlash(n)) }
+	js := NewBundle(p("out/app.js"), p("js/index.js"))
+	other := NewBundle(p("out/other.js"), p("js/missing/*.js"), p("js/b.js"))
+
+	members, err := js.Members()
+	if err == nil {
+		t.Error("Expected error for the missing include")
+	}
+	if exp := []string{p("js/gone.js"), p("js/a.js"), p("js/index.js")}; !reflect.DeepEqual(members, exp) {
+		t.Errorf("Expected %v got %v", exp, members)
+	}
+	if !js.Contains(p("js/a.js")) || !other.Contains(p("js/b.js")) {
+		t.Error("Expected members after a missing file to be found")
+	}
+
+	var terr strings.Builder
+	ti := &goauto.TaskInfo{Src: p("js/a.js"), Tout: ioutil.Discard, Terr: &terr}
+	if err = NewBundleTask(js, other).Run(ti); err == nil {
+		t.Error("Expected the bundle with a missing include to fail")
+	}
+	if !strings.Contains(terr.String(), "no files match") {
+		t.Errorf("Expected the unbuilt bundle error reported got %q", terr.String())
+	}
+}
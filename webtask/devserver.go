@@ -0,0 +1,164 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A DevServer serves the static files in a directory for front end development
+// Responses are sent with Cache-Control: no-cache and an ETag so the browser always revalidates
+// but unchanged files are not downloaded again
+// With a LiveReload the client script is injected into each HTML page and the events are
+// served from the same address, no ScriptTag is needed
+// A DevServer is a goauto.Service, use Pipeline.Manage to run it with a Pipeline
+type DevServer struct {
+	Addr       string      // Address to listen on, i.e. ":8000"
+	Root       string      // Directory to serve
+	LiveReload *LiveReload // Optional LiveReload to inject into HTML pages
+	SPA        bool        // Serve Root/index.html for paths without an extension that do not exist
+	Listing    bool        // List directories without an index.html
+	bg         server
+}
+
+// NewDevServer returns a DevServer for root listening on addr
+// lr may be nil
+func NewDevServer(addr, root string, lr *LiveReload) *DevServer {
+	return &DevServer{Addr: addr, Root: root, LiveReload: lr}
+}
+
+// Start listens on Addr and serves Root in the background
+func (ds *DevServer) Start() error {
+	if _, err := os.Stat(ds.Root); err != nil {
+		return err
+	}
+	return ds.bg.start(ds.Addr, ds)
+}
+
+// Stop closes the listener and any open connections
+func (ds *DevServer) Stop() error {
+	return ds.bg.stop()
+}
+
+// ServeHTTP serves the file in Root for r
+func (ds *DevServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ds.LiveReload != nil && (r.URL.Path == LiveReloadEvents || r.URL.Path == LiveReloadScript) {
+		ds.LiveReload.ServeHTTP(w, r)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+
+	upath := path.Clean("/" + r.URL.Path)
+	fpath := filepath.Join(ds.Root, filepath.FromSlash(upath))
+	fi, err := os.Stat(fpath)
+	switch {
+	case err != nil && ds.SPA && path.Ext(upath) == "":
+		ds.serveFile(w, r, filepath.Join(ds.Root, "index.html"))
+	case err != nil:
+		http.NotFound(w, r)
+	case fi.IsDir() && !strings.HasSuffix(r.URL.Path, "/"):
+		// the cleaned path has a single leading slash so //host/dir can not redirect off site
+		u := &url.URL{Path: strings.TrimSuffix(upath, "/") + "/", RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+	case fi.IsDir():
+		index := filepath.Join(fpath, "index.html")
+		if _, err := os.Stat(index); err == nil {
+			ds.serveFile(w, r, index)
+		} else if ds.Listing {
+			ds.list(w, upath, fpath)
+		} else {
+			http.NotFound(w, r)
+		}
+	default:
+		ds.serveFile(w, r, fpath)
+	}
+}
+
+func (ds *DevServer) serveFile(w http.ResponseWriter, r *http.Request, fpath string) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	var content io.ReadSeeker = f
+	ext := strings.ToLower(filepath.Ext(fpath))
+	if ds.LiveReload != nil && (ext == ".html" || ext == ".htm") {
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(injectScript(b, fmt.Sprintf(`<script src="%v"></script>`, LiveReloadScript)))
+	}
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), content)
+}
+
+// injectScript adds tag before the closing body tag of page, or at the end if there is none
+func injectScript(page []byte, tag string) []byte {
+	i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+	if i < 0 {
+		return append(page, tag...)
+	}
+	out := make([]byte, 0, len(page)+len(tag))
+	out = append(out, page[:i]...)
+	out = append(out, tag...)
+	return append(out, page[i:]...)
+}
+
+var listTmpl = template.Must(template.New("list").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Path}}</title></head>
+<body style="font-family: sans-serif">
+<h1>{{.Path}}</h1>
+<ul>
+{{if ne .Path "/"}}<li><a href="../">../</a></li>{{end}}
+{{range .Names}}<li><a href="{{.}}">{{.}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+func (ds *DevServer) list(w http.ResponseWriter, upath, dir string) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var names []string
+	for _, fi := range fis {
+		n := fi.Name()
+		if fi.IsDir() {
+			n += "/"
+		}
+		names = append(names, n)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	listTmpl.Execute(w, struct {
+		Path  string
+		Names []string
+	}{upath, names})
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func serve(ds *DevServer, path string, hdr ...string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", path, nil)
+	for i := 0; i+1 < len(hdr); i += 2 {
+		req.Header.Set(hdr[i], hdr[i+1])
+	}
+	ds.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDevServer(t *testing.T) {
+	root, err := ioutil.TempDir("", "goauto-dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "css"), 0755)
+	os.MkdirAll(filepath.Join(root, "docs"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "index.html"), []byte("<html><body><h1>App</h1></BODY></html>"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "css", "main.css"), []byte("body{}"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "docs", "a.txt"), []byte("a"), 0644)
+	os.MkdirAll(filepath.Join(root, "docs", "sub"), 0755)
+
+	ds := NewDevServer(":0", root, NewLiveReload(":0"))
+	rec := serve(ds, "/")
+	if body := rec.Body.String(); body != `<html><body><h1>App</h1><script src="/livereload.js"></script></BODY></html>` {
+		t.Errorf("Expected injected script got %q", body)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Expected no-cache got %v", cc)
+	}
+
+	rec = serve(ds, "/css/main.css")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != 200 || rec.Body.String() != "body{}" || etag == "" {
+		t.Errorf("Unexpected css response %v %q %v", rec.Code, rec.Body.String(), etag)
+	}
+	if rec = serve(ds, "/css/main.css", "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("Expected not modified got %v", rec.Code)
+	}
+
+	for p, loc := range map[string]string{"/docs": "/docs/", "//docs": "/docs/", "/docs/sub?v=1": "/docs/sub/?v=1", "/docs/../docs": "/docs/"} {
+		if rec = serve(ds, p); rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != loc {
+			t.Errorf("Expected %v to redirect to %v got %v %v", p, loc, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+
+	if rec = serve(ds, "/app/settings"); rec.Code != 404 {
+		t.Errorf("Expected 404 without SPA got %v", rec.Code)
+	}
+	ds.SPA = true
+	if rec = serve(ds, "/app/settings"); rec.Code != 200 || !strings.Contains(rec.Body.String(), "<h1>App</h1>") {
+		t.Errorf("Expected index.html for SPA route got %v", rec.Code)
+	}
+	if rec = serve(ds, "/missing.js"); rec.Code != 404 {
+		t.Errorf("Expected 404 for missing file got %v", rec.Code)
+	}
+
+	if rec = serve(ds, "/docs"); rec.Code != http.StatusMovedPermanently {
+		t.Errorf("Expected redirect got %v", rec.Code)
+	}
+	if rec = serve(ds, "/docs/"); rec.Code != 404 {
+		t.Errorf("Expected 404 without Listing got %v", rec.Code)
+	}
+	ds.Listing = true
+	rec = serve(ds, "/docs/")
+	if body := rec.Body.String(); !strings.Contains(body, `<a href="a.txt">a.txt</a>`) || !strings.Contains(body, `<a href="sub/">sub/</a>`) {
+		t.Errorf("Expected listing got %q", body)
+	}
+
+	ds.SPA = false
+	if rec = serve(ds, "/../../etc/passwd"); rec.Code != 404 {
+		t.Errorf("Expected 404 outside Root got %v", rec.Code)
+	}
+	if rec = serve(ds, LiveReloadScript); !strings.Contains(rec.Body.String(), "EventSource") {
+		t.Error("Expected the live reload script")
+	}
+}
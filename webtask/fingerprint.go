@@ -0,0 +1,172 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dshills/goauto"
+)
+
+const hashLen = 8 // hex digits in a fingerprint
+
+// A Manifest maps logical asset names such as css/app.css to fingerprinted names such as css/app.3f9a1c2b.css
+// Names are slash separated and relative to Root
+// The manifest is kept in File as JSON so it can be read by the application serving the assets
+type Manifest struct {
+	File   string
+	Root   string // Directory names are relative to, defaults to the directory of File
+	Prefix string // Prepended to names returned by Lookup, i.e. /static/
+	mu     sync.Mutex
+	assets map[string]string
+}
+
+// NewManifest returns a Manifest kept in file, loading the existing entries if any
+func NewManifest(file, root string) (*Manifest, error) {
+	if root == "" {
+		root = filepath.Dir(file)
+	}
+	// Pipeline Events have absolute paths
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{File: file, Root: root, assets: make(map[string]string)}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &m.assets); err != nil {
+		return nil, fmt.Errorf("Manifest %v: %v", file, err)
+	}
+	return m, nil
+}
+
+// Lookup returns the fingerprinted name for name, or name if it is not in the Manifest
+func (m *Manifest) Lookup(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = strings.TrimPrefix(name, "/")
+	if h, ok := m.assets[name]; ok {
+		name = h
+	}
+	return m.Prefix + name
+}
+
+// FuncMap returns an html/template FuncMap with an asset function that calls Lookup
+//
+//	t := template.New("page").Funcs(m.FuncMap())
+//	<link rel="stylesheet" href="{{asset "css/app.css"}}">
+func (m *Manifest) FuncMap() template.FuncMap {
+	return template.FuncMap{"asset": m.Lookup}
+}
+
+// generated returns true if name is the fingerprinted name of an asset
+func (m *Manifest) generated(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, h := range m.assets {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
+// set records the fingerprinted name of name and saves the Manifest
+// returns the name it replaced if any
+func (m *Manifest) set(name, hashed string) (old string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old = m.assets[name]
+	m.assets[name] = hashed
+	b, err := json.MarshalIndent(m.assets, "", "  ")
+	if err != nil {
+		return
+	}
+	tmp := m.File + ".tmp"
+	if err = ioutil.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return
+	}
+	err = os.Rename(tmp, m.File)
+	return
+}
+
+type fingerprintTask struct {
+	m *Manifest
+}
+
+// NewFingerprintTask returns a Task that copies TaskInfo.Src to a name with a hash of its contents,
+// app.css becomes app.3f9a1c2b.css, and records it in m
+// The copy recorded before it in m is removed
+// Files recorded in m as fingerprinted names are skipped so the copies do not trigger the Workflow again
+// TaskInfo.Target is set to the fingerprinted file
+func NewFingerprintTask(m *Manifest) goauto.Tasker {
+	return &fingerprintTask{m: m}
+}
+
+func (ft *fingerprintTask) Run(info *goauto.TaskInfo) (err error) {
+	info.Buf.Reset()
+	root, err := filepath.Abs(ft.m.Root)
+	if err != nil {
+		return
+	}
+	src, err := filepath.Abs(info.Src)
+	if err != nil {
+		return
+	}
+	name, err := filepath.Rel(root, src)
+	if err != nil {
+		return
+	}
+	name = filepath.ToSlash(name)
+	if ft.m.generated(name) {
+		info.Target = info.Src
+		return
+	}
+	b, err := ioutil.ReadFile(info.Src)
+	if err != nil {
+		return
+	}
+
+	sum := sha256.Sum256(b)
+	ext := filepath.Ext(info.Src)
+	base := strings.TrimSuffix(info.Src, ext)
+	target := base + "." + hex.EncodeToString(sum[:])[:hashLen] + ext
+	if _, err = os.Stat(target); err != nil {
+		if err = ioutil.WriteFile(target, b, 0644); err != nil {
+			return
+		}
+	}
+	info.Target = target
+
+	hashed := path.Join(path.Dir(name), filepath.Base(target))
+	old, err := ft.m.set(name, hashed)
+	if err != nil {
+		return
+	}
+	if old != "" && old != hashed {
+		if err = os.Remove(filepath.Join(root, filepath.FromSlash(old))); err != nil && !os.IsNotExist(err) {
+			return
+		}
+		err = nil
+	}
+	if info.Verbose && old != hashed {
+		fmt.Fprintf(info.Tout, "<< %v -> %v\n", name, hashed)
+	}
+	return
+}
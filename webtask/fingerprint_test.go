@@ -0,0 +1,124 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dshills/goauto"
+)
+
+func TestFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-fp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"css/app.css":         "body{}",
+		"css/app.min.css":     "keep",
+		"report.css":          "new",
+		"report.20240101.css": "dated",
+	})
+	m, err := NewManifest(filepath.Join(dir, "manifest.json"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "css", "app.css")
+	ti := &goauto.TaskInfo{Src: src, Tout: ioutil.Discard, Terr: ioutil.Discard}
+	if err = NewFingerprintTask(m).Run(ti); err != nil {
+		t.Fatal(err)
+	}
+	first := ti.Target
+	if filepath.Base(first) != "app.7c98040a.css" {
+		t.Errorf("Unexpected fingerprint %v", first)
+	}
+
+	// the copy does not get fingerprinted
+	ti.Src = first
+	if err = NewFingerprintTask(m).Run(ti); err != nil || ti.Target != first {
+		t.Errorf("Expected fingerprinted file skipped got %v %v", ti.Target, err)
+	}
+
+	ioutil.WriteFile(src, []byte("body{color:red}"), 0644)
+	ti.Src = src
+	if err = NewFingerprintTask(m).Run(ti); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(first); !os.IsNotExist(err) {
+		t.Error("Expected stale fingerprint removed")
+	}
+	if _, err = os.Stat(filepath.Join(dir, "css", "app.min.css")); err != nil {
+		t.Error("Expected unrelated file kept")
+	}
+
+	// names that look fingerprinted are only skipped if they are in the Manifest
+	for _, f := range []string{"report.20240101.css", "report.css"} {
+		ti.Src = filepath.Join(dir, f)
+		if err = NewFingerprintTask(m).Run(ti); err != nil {
+			t.Fatal(err)
+		}
+		if ti.Target == ti.Src {
+			t.Errorf("Expected %v to be fingerprinted", f)
+		}
+	}
+	for _, f := range []string{"report.20240101.css", "report.css"} {
+		if _, err = os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("Expected %v kept", f)
+		}
+	}
+
+	// the manifest is read back by a new Manifest as an application would
+	m2, err := NewManifest(filepath.Join(dir, "manifest.json"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2.Prefix = "/static/"
+	exp := "/static/css/" + filepath.Base(m.Lookup("css/app.css"))
+	if n := m2.Lookup("css/app.css"); n != exp {
+		t.Errorf("Expected %v got %v", exp, n)
+	}
+	if n := m2.Lookup("/img/logo.png"); n != "/static/img/logo.png" {
+		t.Errorf("Expected unknown name unchanged got %v", n)
+	}
+
+	tmpl := template.Must(template.New("page").Funcs(m2.FuncMap()).Parse(`<link href="{{asset "css/app.css"}}">`))
+	var out bytes.Buffer
+	if err = tmpl.Execute(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s := out.String(); s != `<link href="`+exp+`">` {
+		t.Errorf("Unexpected template output %v", s)
+	}
+}
+
+func TestFingerprintRelativeRoot(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir(wd, "goauto-fp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"css/app.css": "body{}"})
+	rel := filepath.Base(dir)
+	m, err := NewManifest(filepath.Join(rel, "manifest.json"), rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti := &goauto.TaskInfo{Src: filepath.Join(dir, "css", "app.css"), Tout: ioutil.Discard, Terr: ioutil.Discard}
+	if err = NewFingerprintTask(m).Run(ti); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.Lookup("css/app.css"); n != "css/app.7c98040a.css" {
+		t.Errorf("Expected css/app.7c98040a.css got %v", n)
+	}
+}
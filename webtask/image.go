@@ -0,0 +1,236 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dshills/goauto"
+)
+
+const defaultQuality = 85
+
+// SuffixTransformer returns a Transformer that adds suffix to the file name, photo.jpg becomes photo-thumb.jpg for -thumb
+func SuffixTransformer(suffix string) goauto.Transformer {
+	return func(f string) string {
+		ext := filepath.Ext(f)
+		return strings.TrimSuffix(f, ext) + suffix + ext
+	}
+}
+
+// IsImage returns true for the PNG, JPEG and GIF files an ImageTask can read
+func IsImage(f string) bool {
+	switch strings.ToLower(filepath.Ext(f)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	}
+	return false
+}
+
+// An ImageVariant is an image written by an ImageTask
+// The format is chosen by the extension of the target so a variant can also convert, i.e. PNG to JPEG
+type ImageVariant struct {
+	Transform goauto.Transformer
+	Width     int  // Maximum width, 0 for any
+	Height    int  // Maximum height, 0 for any
+	Crop      bool // Fill Width x Height exactly by cropping the center of the image
+	Quality   int  // JPEG quality from 1 to 100, defaults to the ImageTask Quality
+}
+
+// An ImageTask writes resized and re-encoded variants of an image, all in Go
+// Images are never enlarged, EXIF orientation is applied and metadata is not copied
+// JPEG targets of transparent images get a white background and GIFs are written as a single frame
+// Files the task writes are skipped so variants in the watched directory do not trigger the Workflow again
+// Variants written before a restart are recognized when their source is in the same directory,
+// the parent directory or the directory of an image already run
+type ImageTask struct {
+	Variants []*ImageVariant
+	Quality  int // JPEG quality from 1 to 100, defaults to 85
+	mu       sync.Mutex
+	written  map[string]bool
+	srcDirs  map[string]bool // directories of the images run
+}
+
+// NewImageTask returns an ImageTask that writes the variants
+func NewImageTask(variants ...*ImageVariant) *ImageTask {
+	return &ImageTask{Variants: variants}
+}
+
+// NewResizeTask returns an ImageTask that scales images to fit within width x height
+// A zero width or height leaves that side unconstrained
+func NewResizeTask(t goauto.Transformer, width, height int) *ImageTask {
+	return NewImageTask(&ImageVariant{Transform: t, Width: width, Height: height})
+}
+
+// NewThumbnailTask returns an ImageTask that scales and crops images to width x height
+func NewThumbnailTask(t goauto.Transformer, width, height int) *ImageTask {
+	return NewImageTask(&ImageVariant{Transform: t, Width: width, Height: height, Crop: true})
+}
+
+// NewReencodeTask returns an ImageTask that re-encodes images at their size with quality, dropping their metadata
+func NewReencodeTask(t goauto.Transformer, quality int) *ImageTask {
+	return NewImageTask(&ImageVariant{Transform: t, Quality: quality})
+}
+
+// Target returns the Target of the first variant for src
+func (it *ImageTask) Target(src string) string {
+	if len(it.Variants) == 0 {
+		return src
+	}
+	return it.Variants[0].Transform(src)
+}
+
+// Run will write each variant of TaskInfo.Src
+// goauto.TaskInfo.Target is set to the first variant
+// If TaskInfo.Src was removed its variants are removed
+func (it *ImageTask) Run(info *goauto.TaskInfo) (err error) {
+	info.Target = it.Target(info.Src)
+	info.Buf.Reset()
+	if len(it.Variants) == 0 {
+		return errors.New("No image variants")
+	}
+	for _, v := range it.Variants {
+		if v.Transform(info.Src) == info.Src {
+			return fmt.Errorf("%v: An ImageTask can not overwrite its source", info.Src)
+		}
+	}
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.generated(info.Src) {
+		info.Target = info.Src
+		return
+	}
+
+	b, err := ioutil.ReadFile(info.Src)
+	if os.IsNotExist(err) {
+		for _, v := range it.Variants {
+			if err = os.Remove(v.Transform(info.Src)); err != nil && !os.IsNotExist(err) {
+				return
+			}
+		}
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	if it.srcDirs == nil {
+		it.srcDirs = make(map[string]bool)
+	}
+	it.srcDirs[filepath.Dir(info.Src)] = true
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("%v: %v", info.Src, err)
+	}
+	src := orient(toRGBA(img), jpegOrientation(b))
+
+	for _, v := range it.Variants {
+		target := v.Transform(info.Src)
+		out := v.resize(src)
+		q := v.Quality
+		if q == 0 {
+			q = it.Quality
+		}
+		var buf bytes.Buffer
+		if err = encodeImage(&buf, out, target, q); err != nil {
+			return
+		}
+		if err = writeFileAll(target, buf.Bytes()); err != nil {
+			return
+		}
+		if it.written == nil {
+			it.written = make(map[string]bool)
+		}
+		it.written[target] = true
+		if info.Verbose {
+			fmt.Fprintf(info.Tout, "<< %v -> %v %vx%v %v\n", filepath.Base(info.Src), filepath.Base(target), out.Bounds().Dx(), out.Bounds().Dy(), sizeReport(buf.Len()))
+		}
+	}
+	return
+}
+
+// generated returns true if f is a variant of another image
+// Sources are looked for next to f, in its parent directory and in the directories of the images run
+func (it *ImageTask) generated(f string) bool {
+	if it.written[f] {
+		return true
+	}
+	dirs := []string{filepath.Dir(f), filepath.Dir(filepath.Dir(f))}
+	for d := range it.srcDirs {
+		dirs = append(dirs, d)
+	}
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, fi := range fis {
+			src := filepath.Join(dir, fi.Name())
+			if src == f || fi.IsDir() || !IsImage(src) {
+				continue
+			}
+			for _, v := range it.Variants {
+				if v.Transform(src) == f {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// resize returns img scaled and cropped for the variant
+func (v *ImageVariant) resize(img *image.RGBA) *image.RGBA {
+	sw, sh := img.Bounds().Dx(), img.Bounds().Dy()
+	if !v.Crop || v.Width <= 0 || v.Height <= 0 {
+		w, h := fitSize(sw, sh, v.Width, v.Height)
+		return resize(img, w, h)
+	}
+	r := cropRect(sw, sh, v.Width, v.Height)
+	w, h := v.Width, v.Height
+	if r.Dx() < w {
+		// too small to fill, keep the aspect ratio at the cropped size
+		w, h = r.Dx(), r.Dy()
+	}
+	return resize(toRGBA(img.SubImage(r)), w, h)
+}
+
+// encodeImage encodes img in the format of the target file extension
+func encodeImage(buf *bytes.Buffer, img *image.RGBA, target string, quality int) error {
+	switch strings.ToLower(filepath.Ext(target)) {
+	case ".jpg", ".jpeg":
+		if quality <= 0 || quality > 100 {
+			quality = defaultQuality
+		}
+		if !img.Opaque() {
+			bg := image.NewRGBA(img.Bounds())
+			draw.Draw(bg, bg.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+			draw.Draw(bg, bg.Bounds(), img, image.Point{}, draw.Over)
+			img = bg
+		}
+		return jpeg.Encode(buf, img, &jpeg.Options{Quality: quality})
+	case ".png":
+		return (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(buf, img)
+	case ".gif":
+		return gif.Encode(buf, img, nil)
+	}
+	return fmt.Errorf("%v: Unsupported image format", target)
+}
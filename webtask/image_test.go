@@ -0,0 +1,186 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dshills/goauto"
+)
+
+func writeImage(t *testing.T, f string, img image.Image) {
+	var buf bytes.Buffer
+	var err error
+	if filepath.Ext(f) == ".png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(f, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readImage(t *testing.T, f string) image.Image {
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestImageTask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// red on the left, transparent on the right
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			img.Set(x, y, color.NRGBA{255, 0, 0, 255})
+		}
+	}
+	src := filepath.Join(dir, "photo.png")
+	writeImage(t, src, img)
+
+	it := NewImageTask(
+		&ImageVariant{Transform: SuffixTransformer("-small"), Width: 100},
+		&ImageVariant{Transform: SuffixTransformer("-thumb"), Width: 50, Height: 50, Crop: true},
+		&ImageVariant{Transform: func(f string) string { return f[:len(f)-4] + ".jpg" }, Height: 1000},
+	)
+	info := &goauto.TaskInfo{Src: src, Tout: ioutil.Discard, Terr: ioutil.Discard}
+	if err = it.Run(info); err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "photo-small.png"); info.Target != want {
+		t.Errorf("Expected Target %v got %v", want, info.Target)
+	}
+	for f, size := range map[string]image.Point{"photo-small.png": {100, 50}, "photo-thumb.png": {50, 50}, "photo.jpg": {400, 200}} {
+		if got := readImage(t, filepath.Join(dir, f)).Bounds().Size(); got != size {
+			t.Errorf("Expected %v to be %v got %v", f, size, got)
+		}
+	}
+	small := readImage(t, filepath.Join(dir, "photo-small.png"))
+	if r, g, b, a := small.At(10, 10).RGBA(); r>>8 != 255 || g != 0 || b != 0 || a>>8 != 255 {
+		t.Errorf("Expected red got %v %v %v %v", r, g, b, a)
+	}
+	if _, _, _, a := small.At(90, 40).RGBA(); a != 0 {
+		t.Errorf("Expected transparent got %v", a)
+	}
+	// the thumbnail is the center of the image
+	if _, _, _, a := readImage(t, filepath.Join(dir, "photo-thumb.png")).At(40, 25).RGBA(); a != 0 {
+		t.Errorf("Expected the right half of the thumbnail to be transparent got %v", a)
+	}
+	// JPEGs get a white background
+	if r, g, b, _ := readImage(t, filepath.Join(dir, "photo.jpg")).At(390, 100).RGBA(); r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+		t.Errorf("Expected white got %v %v %v", r>>8, g>>8, b>>8)
+	}
+
+	// variants are not processed again
+	info = &goauto.TaskInfo{Src: filepath.Join(dir, "photo-small.png"), Tout: ioutil.Discard, Terr: ioutil.Discard}
+	if err = it.Run(info); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "photo-small-small.png")); !os.IsNotExist(err) {
+		t.Error("Expected a variant to be skipped")
+	}
+	// a new task finds them by name
+	if it2 := NewResizeTask(SuffixTransformer("-small"), 10, 0); !it2.generated(info.Src) || it2.generated(src) {
+		t.Error("Expected photo-small.png to be a variant of photo.png")
+	}
+
+	// variants in a directory below the source are found after a restart
+	thumbs := func(f string) string { return filepath.Join(filepath.Dir(f), "thumbs", filepath.Base(f)) }
+	if err = NewThumbnailTask(thumbs, 20, 20).Run(&goauto.TaskInfo{Src: src, Tout: ioutil.Discard, Terr: ioutil.Discard}); err != nil {
+		t.Fatal(err)
+	}
+	thumb := filepath.Join(dir, "thumbs", "photo.png")
+	if it2 := NewThumbnailTask(thumbs, 20, 20); !it2.generated(thumb) || it2.generated(src) {
+		t.Error("Expected thumbs/photo.png to be a variant of photo.png")
+	}
+	os.RemoveAll(filepath.Join(dir, "thumbs"))
+
+	// removing the image removes its variants
+	os.Remove(src)
+	if err = it.Run(&goauto.TaskInfo{Src: src, Tout: ioutil.Discard, Terr: ioutil.Discard}); err != nil {
+		t.Fatal(err)
+	}
+	if fis, _ := ioutil.ReadDir(dir); len(fis) != 0 {
+		t.Errorf("Expected the variants to be removed, found %v", len(fis))
+	}
+
+	if err = NewReencodeTask(goauto.Identity, 80).Run(info); err == nil {
+		t.Error("Expected an error overwriting the source")
+	}
+}
+
+func TestImageOrientation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.White) // white on the left
+		}
+	}
+	var buf bytes.Buffer
+	if err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	// APP1 Exif, big endian TIFF with one IFD entry, orientation 6
+	exif := []byte("\xff\xe1\x00\x22Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00\x00\x00\x00\x00")
+	b := append(append([]byte{0xff, 0xd8}, exif...), buf.Bytes()[2:]...)
+	if o := jpegOrientation(b); o != 6 {
+		t.Fatalf("Expected orientation 6 got %v", o)
+	}
+	src := filepath.Join(dir, "phone.jpg")
+	if err = ioutil.WriteFile(src, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	it := NewReencodeTask(SuffixTransformer("-web"), 90)
+	if err = it.Run(&goauto.TaskInfo{Src: src, Tout: ioutil.Discard, Terr: ioutil.Discard}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadFile(filepath.Join(dir, "phone-web.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out, []byte("Exif")) {
+		t.Error("Expected the metadata to be stripped")
+	}
+	up, _, err := image.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// turned clockwise the white left side is at the top
+	if size := up.Bounds().Size(); size != (image.Point{20, 40}) {
+		t.Errorf("Expected 20x40 got %v", size)
+	}
+	if r, _, _, _ := up.At(10, 2).RGBA(); r>>8 < 200 {
+		t.Errorf("Expected white at the top got %v", r>>8)
+	}
+	if r, _, _, _ := up.At(10, 38).RGBA(); r>>8 > 50 {
+		t.Errorf("Expected black at the bottom got %v", r>>8)
+	}
+}
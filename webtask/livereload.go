@@ -0,0 +1,169 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dshills/goauto"
+)
+
+// Paths served by a LiveReload
+const (
+	LiveReloadEvents = "/livereload"
+	LiveReloadScript = "/livereload.js"
+)
+
+const liveReloadScript = `(function() {
+	var es = new EventSource(new URL("` + LiveReloadEvents + `", document.currentScript.src));
+	es.addEventListener("reload", function() { location.reload(); });
+	es.addEventListener("css", function(e) {
+		var found = false;
+		document.querySelectorAll('link[rel="stylesheet"]').forEach(function(l) {
+			var u = new URL(l.href);
+			if (u.pathname.split("/").pop() === e.data) {
+				u.searchParams.set("livereload", Date.now());
+				l.href = u.toString();
+				found = true;
+			}
+		});
+		if (!found) { location.reload(); }
+	});
+})();
+`
+
+// A LiveReload is a server that tells connected browsers to reload when files change
+// Pages load the client script from LiveReloadScript, see ScriptTag, which listens for
+// Server-Sent Events on LiveReloadEvents
+// Changed stylesheets are swapped in place, any other change reloads the page
+// A LiveReload is a goauto.Service, use Pipeline.Manage to run it with a Pipeline
+// It is also an http.Handler that can be added to an existing server
+type LiveReload struct {
+	Addr    string // Address to listen on, i.e. ":35729"
+	mu      sync.Mutex
+	clients map[chan string]bool
+	bg      server
+}
+
+// NewLiveReload returns a LiveReload listening on addr
+func NewLiveReload(addr string) *LiveReload {
+	return &LiveReload{Addr: addr, clients: make(map[chan string]bool)}
+}
+
+// ScriptTag returns the script element to add to pages served on host, i.e. ScriptTag("localhost")
+func (lr *LiveReload) ScriptTag(host string) string {
+	_, port, _ := net.SplitHostPort(lr.Addr)
+	return fmt.Sprintf(`<script src="//%v%v"></script>`, net.JoinHostPort(host, port), LiveReloadScript)
+}
+
+// Start listens on Addr and serves browsers in the background
+func (lr *LiveReload) Start() error {
+	return lr.bg.start(lr.Addr, lr)
+}
+
+// Stop closes the listener and disconnects the browsers
+func (lr *LiveReload) Stop() error {
+	return lr.bg.stop()
+}
+
+// Clients returns the number of connected browsers
+func (lr *LiveReload) Clients() int {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return len(lr.clients)
+}
+
+// Reload tells the browsers that fpath changed
+func (lr *LiveReload) Reload(fpath string) {
+	msg := fmt.Sprintf("event: reload\ndata: %v\n\n", filepath.Base(fpath))
+	if strings.EqualFold(filepath.Ext(fpath), ".css") {
+		msg = fmt.Sprintf("event: css\ndata: %v\n\n", filepath.Base(fpath))
+	}
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for c := range lr.clients {
+		select {
+		case c <- msg:
+		default: // slow browser, it will catch the next one
+		}
+	}
+}
+
+// ServeHTTP serves the client script and the event stream
+func (lr *LiveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case LiveReloadScript:
+		w.Header().Set("Content-Type", "application/javascript")
+		w.Header().Set("Cache-Control", "no-cache")
+		fmt.Fprint(w, liveReloadScript)
+	case LiveReloadEvents:
+		lr.events(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (lr *LiveReload) events(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*") // pages are served from another port
+	fmt.Fprint(w, ": connected\n\n")
+	f.Flush()
+
+	c := make(chan string, 8)
+	lr.mu.Lock()
+	if lr.clients == nil {
+		lr.clients = make(map[chan string]bool)
+	}
+	lr.clients[c] = true
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, c)
+		lr.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case msg := <-c:
+			if _, err := fmt.Fprint(w, msg); err != nil {
+				return
+			}
+			f.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+type liveReloadTask struct {
+	lr *LiveReload
+}
+
+// NewLiveReloadTask returns a Task that tells the browsers connected to lr that TaskInfo.Src changed
+// Add it at the end of a Workflow so TaskInfo.Src is the final Target, i.e. the compiled .css file
+// goauto.TaskInfo.Target is set to goauto.TaskInfo.Src
+func NewLiveReloadTask(lr *LiveReload) goauto.Tasker {
+	return &liveReloadTask{lr: lr}
+}
+
+func (lt *liveReloadTask) Run(info *goauto.TaskInfo) error {
+	info.Target = info.Src
+	info.Buf.Reset()
+	lt.lr.Reload(info.Src)
+	if info.Verbose {
+		fmt.Fprintf(info.Tout, "<< Reloaded %v in %v browsers\n", filepath.Base(info.Src), lt.lr.Clients())
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/goauto"
+)
+
+// nextEvent reads an event and its data from an event stream
+func nextEvent(r *bufio.Reader) (event, data string) {
+	for {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		l = strings.TrimSpace(l)
+		switch {
+		case strings.HasPrefix(l, "event: "):
+			event = l[7:]
+		case strings.HasPrefix(l, "data: "):
+			data = l[6:]
+		case l == "" && event != "":
+			return
+		}
+	}
+}
+
+func TestLiveReload(t *testing.T) {
+	lr := NewLiveReload("127.0.0.1:35729")
+	ts := httptest.NewServer(lr)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + LiveReloadScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(b), "EventSource") {
+		t.Error("Expected the client script")
+	}
+
+	resp, err = http.Get(ts.URL + LiveReloadEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected an event stream got %v", ct)
+	}
+	for i := 0; lr.Clients() == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	r := bufio.NewReader(resp.Body)
+
+	ti := &goauto.TaskInfo{Src: "/site/css/main.css", Tout: ioutil.Discard, Terr: ioutil.Discard}
+	if err = NewLiveReloadTask(lr).Run(ti); err != nil {
+		t.Error(err)
+	}
+	if ev, data := nextEvent(r); ev != "css" || data != "main.css" {
+		t.Errorf("Expected css main.css got %v %v", ev, data)
+	}
+	lr.Reload("/site/index.html")
+	if ev, data := nextEvent(r); ev != "reload" || data != "index.html" {
+		t.Errorf("Expected reload index.html got %v %v", ev, data)
+	}
+
+	if tag := lr.ScriptTag("localhost"); tag != `<script src="//localhost:35729/livereload.js"></script>` {
+		t.Errorf("Unexpected script tag %v", tag)
+	}
+}
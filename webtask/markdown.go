@@ -0,0 +1,935 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Markdown renders CommonMark to HTML with GitHub style tables
+// Headings, paragraphs, block quotes, lists, code blocks, fenced code, thematic breaks,
+// HTML blocks, emphasis, code spans, links, reference links, images and autolinks are supported
+func Markdown(src []byte) []byte {
+	s := strings.Replace(string(src), "\r\n", "\n", -1)
+	m := &markdown{refs: make(map[string]mdLink)}
+	blocks := m.parse(strings.Split(s, "\n"))
+	var out bytes.Buffer
+	m.render(&out, blocks, false)
+	return out.Bytes()
+}
+
+const (
+	mdPara = iota
+	mdHeading
+	mdCode
+	mdHTML
+	mdRule
+	mdQuote
+	mdList
+	mdItem
+	mdTable
+)
+
+// mdBlock is a parsed block
+type mdBlock struct {
+	kind       int
+	level      int    // heading level
+	text       string // inline content, code or raw html
+	info       string // fenced code info string
+	children   []*mdBlock
+	ordered    bool
+	start      int
+	tight      bool
+	align      []string
+	rows       [][]string // table header and body cells
+	afterBlank bool       // preceded by a blank line
+}
+
+// mdLink is a link reference definition
+type mdLink struct {
+	dest, title string
+}
+
+type markdown struct {
+	refs map[string]mdLink
+}
+
+var (
+	mdATX       = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdRuleLine  = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdFence     = regexp.MustCompile("^(`{3,}|~{3,})[ \t]*(.*)$")
+	mdSetext    = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	mdBullet    = regexp.MustCompile(`^([*+-])( {1,}|$)`)
+	mdOrdered   = regexp.MustCompile(`^(\d{1,9})([.)])( {1,}|$)`)
+	mdRefDef    = regexp.MustCompile(`^\[((?:[^\]\\]|\\.)+)\]:[ \t]*(<[^>\n]*>|\S+)(?:[ \t]+("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|\((?:[^)\\]|\\.)*\)))?[ \t]*$`)
+	mdTableSep  = regexp.MustCompile(`^\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	mdHTMLStart = regexp.MustCompile(`^<(/?)([A-Za-z][A-Za-z0-9-]*)(?:[\s/>]|$)`)
+	mdTag       = `(?:<[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>)`
+	mdHTMLLine  = regexp.MustCompile(`^` + mdTag + `[ \t]*$`)
+	mdRawHTML   = regexp.MustCompile(`^(?:` + mdTag + `|<!--(?:[^-]|-[^-])*-->)`)
+	mdAutolink  = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*)>`)
+	mdEmail     = regexp.MustCompile(`^<([A-Za-z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*)>`)
+	mdEntity    = regexp.MustCompile(`^&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+// block level html elements that may interrupt a paragraph
+var mdBlockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true, "details": true,
+	"dd": true, "div": true, "dl": true, "dt": true, "figcaption": true, "figure": true, "footer": true,
+	"form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "html": true, "li": true, "main": true, "nav": true, "ol": true, "p": true, "section": true,
+	"summary": true, "table": true, "tbody": true, "td": true, "tfoot": true, "th": true, "thead": true,
+	"tr": true, "ul": true, "pre": true, "script": true, "style": true, "textarea": true,
+}
+
+// html elements whose content may contain blank lines
+var mdRawTags = map[string]bool{"pre": true, "script": true, "style": true, "textarea": true}
+
+// parse returns the blocks in lines
+func (m *markdown) parse(lines []string) (blocks []*mdBlock) {
+	for i := range lines {
+		lines[i] = expandTabs(lines[i])
+	}
+	blank := false
+	add := func(b *mdBlock) {
+		b.afterBlank = blank
+		blank = false
+		blocks = append(blocks, b)
+	}
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if isBlank(line) {
+			blank = true
+			i++
+			continue
+		}
+		ind := indent(line)
+		if ind >= 4 {
+			var code []string
+			j := i
+			for ; j < len(lines) && (isBlank(lines[j]) || indent(lines[j]) >= 4); j++ {
+				code = append(code, stripIndent(lines[j], 4))
+			}
+			for len(code) > 0 && isBlank(code[len(code)-1]) {
+				code = code[:len(code)-1]
+				j--
+			}
+			add(&mdBlock{kind: mdCode, text: strings.Join(code, "\n") + "\n"})
+			i = j
+			continue
+		}
+		s := line[ind:]
+
+		if h := mdATX.FindStringSubmatch(s); h != nil {
+			add(&mdBlock{kind: mdHeading, level: len(h[1]), text: h[2]})
+			i++
+			continue
+		}
+		if mdRuleLine.MatchString(s) {
+			add(&mdBlock{kind: mdRule})
+			i++
+			continue
+		}
+		if f := mdFence.FindStringSubmatch(s); f != nil && !(f[1][0] == '`' && strings.Contains(f[2], "`")) {
+			var code []string
+			j := i + 1
+			for ; j < len(lines); j++ {
+				l := lines[j]
+				if indent(l) < 4 {
+					c := strings.TrimSpace(l)
+					if strings.HasPrefix(c, f[1]) && strings.Trim(c, f[1][:1]) == "" {
+						j++
+						break
+					}
+				}
+				code = append(code, stripIndent(l, ind))
+			}
+			text := strings.Join(code, "\n")
+			if len(code) > 0 {
+				text += "\n"
+			}
+			add(&mdBlock{kind: mdCode, text: text, info: strings.TrimSpace(unescapeMD(f[2]))})
+			i = j
+			continue
+		}
+		if s[0] == '>' {
+			var quoted []string
+			j := i
+			for ; j < len(lines) && !isBlank(lines[j]); j++ {
+				l := lines[j]
+				if q := strings.TrimLeft(l, " "); indent(l) < 4 && strings.HasPrefix(q, ">") {
+					q = q[1:]
+					if strings.HasPrefix(q, " ") {
+						q = q[1:]
+					}
+					quoted = append(quoted, q)
+				} else if j > i && !m.interrupts(l) {
+					quoted = append(quoted, l) // lazy continuation
+				} else {
+					break
+				}
+			}
+			add(&mdBlock{kind: mdQuote, children: m.parse(quoted)})
+			i = j
+			continue
+		}
+		if m.htmlStart(s, false) {
+			j := i
+			end := ""
+			if strings.HasPrefix(s, "<!--") {
+				end = "-->"
+			} else if t := mdHTMLStart.FindStringSubmatch(s); t != nil && t[1] == "" && mdRawTags[strings.ToLower(t[2])] {
+				end = "</" + strings.ToLower(t[2]) + ">"
+			}
+			for ; j < len(lines); j++ {
+				if end == "" && isBlank(lines[j]) {
+					break
+				}
+				if end != "" && strings.Contains(strings.ToLower(lines[j]), end) {
+					j++
+					break
+				}
+			}
+			add(&mdBlock{kind: mdHTML, text: strings.Join(lines[i:j], "\n") + "\n"})
+			i = j
+			continue
+		}
+		if _, _, _, _, ok := listMarker(s); ok {
+			b, j := m.list(lines, i)
+			add(b)
+			i = j
+			continue
+		}
+		if r := mdRefDef.FindStringSubmatch(s); r != nil {
+			label := normalizeLabel(r[1])
+			if _, ok := m.refs[label]; !ok {
+				dest := strings.TrimSuffix(strings.TrimPrefix(r[2], "<"), ">")
+				title := ""
+				if len(r[3]) > 1 {
+					title = r[3][1 : len(r[3])-1]
+				}
+				m.refs[label] = mdLink{unescapeMD(dest), unescapeMD(title)}
+			}
+			i++
+			continue
+		}
+		if strings.Contains(s, "|") && i+1 < len(lines) && indent(lines[i+1]) < 4 {
+			sep := strings.TrimSpace(lines[i+1])
+			header := splitRow(s)
+			if mdTableSep.MatchString(sep) && strings.Contains(sep, "|") && len(splitRow(sep)) == len(header) {
+				b := &mdBlock{kind: mdTable, rows: [][]string{header}}
+				for _, c := range splitRow(sep) {
+					c = strings.TrimSpace(c)
+					switch {
+					case strings.HasPrefix(c, ":") && strings.HasSuffix(c, ":"):
+						b.align = append(b.align, "center")
+					case strings.HasPrefix(c, ":"):
+						b.align = append(b.align, "left")
+					case strings.HasSuffix(c, ":"):
+						b.align = append(b.align, "right")
+					default:
+						b.align = append(b.align, "")
+					}
+				}
+				j := i + 2
+				for ; j < len(lines) && !isBlank(lines[j]) && !m.interrupts(lines[j]); j++ {
+					b.rows = append(b.rows, splitRow(strings.TrimSpace(lines[j])))
+				}
+				add(b)
+				i = j
+				continue
+			}
+		}
+
+		// paragraph
+		para := []string{s}
+		j := i + 1
+		level := 0
+		for ; j < len(lines) && !isBlank(lines[j]); j++ {
+			if h := mdSetext.FindStringSubmatch(lines[j]); h != nil {
+				level = 1
+				if h[1][0] == '-' {
+					level = 2
+				}
+				j++
+				break
+			}
+			if m.interrupts(lines[j]) {
+				break
+			}
+			para = append(para, strings.TrimLeft(lines[j], " "))
+		}
+		text := strings.TrimRight(strings.Join(para, "\n"), " ")
+		if level > 0 {
+			add(&mdBlock{kind: mdHeading, level: level, text: text})
+		} else {
+			add(&mdBlock{kind: mdPara, text: text})
+		}
+		i = j
+	}
+	return
+}
+
+// interrupts returns true if line starts a block that ends a paragraph
+func (m *markdown) interrupts(line string) bool {
+	if indent(line) >= 4 {
+		return false
+	}
+	s := strings.TrimLeft(line, " ")
+	if s == "" {
+		return true
+	}
+	if mdATX.MatchString(s) || mdRuleLine.MatchString(s) || mdFence.MatchString(s) || s[0] == '>' || m.htmlStart(s, true) {
+		return true
+	}
+	// only non empty lists starting at 1 may interrupt a paragraph
+	ordered, _, start, content, ok := listMarker(s)
+	return ok && strings.TrimSpace(content) != "" && (!ordered || start == 1)
+}
+
+// htmlStart returns true if s starts an html block
+func (m *markdown) htmlStart(s string, interrupt bool) bool {
+	if strings.HasPrefix(s, "<!--") {
+		return true
+	}
+	t := mdHTMLStart.FindStringSubmatch(s)
+	if t == nil {
+		return false
+	}
+	return mdBlockTags[strings.ToLower(t[2])] || !interrupt && mdHTMLLine.MatchString(s)
+}
+
+// listMarker parses a list item marker at the start of s
+// returns the marker character, the start number of ordered lists and the content after the marker
+func listMarker(s string) (ordered bool, marker byte, start int, content string, ok bool) {
+	if b := mdBullet.FindStringSubmatch(s); b != nil {
+		return false, b[1][0], 0, s[len(b[1]):], true
+	}
+	if o := mdOrdered.FindStringSubmatch(s); o != nil {
+		n, _ := strconv.Atoi(o[1])
+		return true, o[2][0], n, s[len(o[1])+1:], true
+	}
+	return
+}
+
+// list parses the list starting at lines[i]
+// returns the list and the index of the line after it
+func (m *markdown) list(lines []string, i int) (*mdBlock, int) {
+	ind := indent(lines[i])
+	ordered, marker, start, _, _ := listMarker(lines[i][ind:])
+	list := &mdBlock{kind: mdList, ordered: ordered, start: start, tight: true}
+	blankBetween := false
+	for i < len(lines) {
+		ind = indent(lines[i])
+		o, mk, _, content, ok := listMarker(lines[i][ind:])
+		if !ok || o != ordered || mk != marker || ind >= 4 {
+			break
+		}
+		if blankBetween {
+			list.tight = false
+		}
+		// content starts after the marker and its spaces, an empty item or one followed by
+		// indented code starts one space after the marker
+		width := len(lines[i]) - len(content)
+		first := strings.TrimLeft(content, " ")
+		if spaces := indent(content); first == "" || spaces > 4 {
+			width++
+			first = strings.TrimPrefix(content, " ")
+			if first == "" || isBlank(first) {
+				first = ""
+			}
+		} else {
+			width += spaces
+		}
+		item := []string{first}
+		j := i + 1
+		for ; j < len(lines); j++ {
+			l := lines[j]
+			if isBlank(l) {
+				// an item can begin with at most one blank line
+				if len(item) == 1 && item[0] == "" {
+					break
+				}
+				item = append(item, "")
+				continue
+			}
+			if indent(l) >= width {
+				item = append(item, stripIndent(l, width))
+				continue
+			}
+			if _, _, _, _, ok := listMarker(l[indent(l):]); ok {
+				break // next item or a new list
+			}
+			if last := item[len(item)-1]; last != "" && !m.interrupts(l) {
+				item = append(item, l) // lazy continuation
+				continue
+			}
+			break
+		}
+		blankBetween = false
+		for len(item) > 1 && item[len(item)-1] == "" {
+			item = item[:len(item)-1]
+			blankBetween = true
+		}
+		b := &mdBlock{kind: mdItem, children: m.parse(item)}
+		for k, c := range b.children {
+			if k > 0 && c.afterBlank {
+				list.tight = false
+			}
+		}
+		list.children = append(list.children, b)
+		i = j
+	}
+	return list, i
+}
+
+// render writes the html for blocks, tight lists render paragraphs without <p>
+func (m *markdown) render(out *bytes.Buffer, blocks []*mdBlock, tight bool) {
+	for _, b := range blocks {
+		switch b.kind {
+		case mdPara:
+			if tight {
+				out.WriteString(m.inline(b.text))
+				continue
+			}
+			out.WriteString("<p>" + m.inline(b.text) + "</p>\n")
+		case mdHeading:
+			h := strconv.Itoa(b.level)
+			out.WriteString("<h" + h + ">" + m.inline(b.text) + "</h" + h + ">\n")
+		case mdCode:
+			out.WriteString("<pre><code")
+			if b.info != "" {
+				out.WriteString(` class="language-` + mdEscape.Replace(strings.Fields(b.info)[0]) + `"`)
+			}
+			out.WriteString(">" + mdEscape.Replace(b.text) + "</code></pre>\n")
+		case mdHTML:
+			out.WriteString(b.text)
+		case mdRule:
+			out.WriteString("<hr />\n")
+		case mdQuote:
+			out.WriteString("<blockquote>\n")
+			m.render(out, b.children, false)
+			out.WriteString("</blockquote>\n")
+		case mdList:
+			tag := "ul"
+			if b.ordered {
+				tag = "ol"
+			}
+			out.WriteString("<" + tag)
+			if b.ordered && b.start != 1 {
+				out.WriteString(` start="` + strconv.Itoa(b.start) + `"`)
+			}
+			out.WriteString(">\n")
+			for _, item := range b.children {
+				out.WriteString("<li>")
+				for _, c := range item.children {
+					if !(b.tight && c.kind == mdPara) && out.Bytes()[out.Len()-1] != '\n' {
+						out.WriteByte('\n')
+					}
+					m.render(out, []*mdBlock{c}, b.tight)
+				}
+				out.WriteString("</li>\n")
+			}
+			out.WriteString("</" + tag + ">\n")
+		case mdTable:
+			out.WriteString("<table>\n<thead>\n")
+			for r, row := range b.rows {
+				if r == 1 {
+					out.WriteString("<tbody>\n")
+				}
+				cell := "td"
+				if r == 0 {
+					cell = "th"
+				}
+				out.WriteString("<tr>\n")
+				for c, a := range b.align {
+					out.WriteString("<" + cell)
+					if a != "" {
+						out.WriteString(` align="` + a + `"`)
+					}
+					out.WriteString(">")
+					if c < len(row) {
+						out.WriteString(m.inline(strings.TrimSpace(row[c])))
+					}
+					out.WriteString("</" + cell + ">\n")
+				}
+				out.WriteString("</tr>\n")
+				if r == 0 {
+					out.WriteString("</thead>\n")
+				}
+			}
+			if len(b.rows) > 1 {
+				out.WriteString("</tbody>\n")
+			}
+			out.WriteString("</table>\n")
+		}
+	}
+}
+
+// expandTabs replaces tabs in the leading white space of line with spaces to the next multiple of 4
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\t':
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		case ' ':
+			b.WriteByte(' ')
+			col++
+		default:
+			return b.String() + line[i:]
+		}
+	}
+	return b.String()
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// indent returns the number of leading spaces
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// stripIndent removes up to n leading spaces
+func stripIndent(line string, n int) string {
+	if ind := indent(line); ind < n {
+		n = ind
+	}
+	return line[n:]
+}
+
+// splitRow splits a table row on unescaped pipes
+func splitRow(s string) (cells []string) {
+	s = strings.TrimPrefix(s, "|")
+	if strings.HasSuffix(s, "|") && !strings.HasSuffix(s, `\|`) {
+		s = s[:len(s)-1]
+	}
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '|':
+			cells = append(cells, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(cells, s[last:])
+}
+
+// normalizeLabel returns the case and white space insensitive form of a link label
+func normalizeLabel(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// unescapeMD removes backslash escapes and decodes entities
+func unescapeMD(s string) string {
+	if strings.ContainsAny(s, `\&`) {
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			if s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+				i++
+			}
+			b.WriteByte(s[i])
+		}
+		s = html.UnescapeString(b.String())
+	}
+	return s
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < 128 && c > ' ' && !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') && c != 127
+}
+
+// mdInline is a span of rendered html or a delimiter that may become emphasis or a link
+type mdInline struct {
+	html        string
+	delim       byte // '*' or '_' for a delimiter run
+	n, orig     int  // remaining and original length of the delimiter run
+	open, close bool
+	pre, post   string // tags closed before and opened after the delimiter run
+	bracket     bool   // [ or ![
+	image       bool
+	active      bool
+	pos         int // offset of the bracket text in the source
+}
+
+// literal turns a delimiter run into text
+func (t *mdInline) literal() {
+	if t.delim != 0 {
+		t.html = t.pre + strings.Repeat(string(t.delim), t.n) + t.post
+		t.delim = 0
+	}
+}
+
+func (t *mdInline) String() string {
+	if t.delim != 0 {
+		return t.pre + strings.Repeat(string(t.delim), t.n) + t.post
+	}
+	return t.html
+}
+
+// inline renders the inline content s
+func (m *markdown) inline(s string) string {
+	var toks []*mdInline
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			toks = append(toks, &mdInline{html: text.String()})
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch c {
+		case '\\':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				flush()
+				toks = append(toks, &mdInline{html: "<br />\n"})
+				i += 2
+				continue
+			}
+			if i+1 < len(s) && isASCIIPunct(s[i+1]) {
+				text.WriteString(mdEscape.Replace(s[i+1 : i+2]))
+				i += 2
+				continue
+			}
+			text.WriteByte(c)
+			i++
+		case '\n':
+			t := text.String()
+			trimmed := strings.TrimRight(t, " ")
+			text.Reset()
+			text.WriteString(trimmed)
+			if len(t)-len(trimmed) >= 2 {
+				text.WriteString("<br />\n")
+			} else {
+				text.WriteByte('\n')
+			}
+			i++
+			for i < len(s) && s[i] == ' ' {
+				i++
+			}
+		case '`':
+			n := runLen(s, i)
+			end := closingTicks(s, i+n, n)
+			if end < 0 {
+				text.WriteString(s[i : i+n])
+				i += n
+				continue
+			}
+			code := strings.Replace(s[i+n:end], "\n", " ", -1)
+			if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			text.WriteString("<code>" + mdEscape.Replace(code) + "</code>")
+			i = end + n
+		case '*', '_':
+			flush()
+			n := runLen(s, i)
+			prev, _ := utf8.DecodeLastRuneInString(s[:i])
+			next, _ := utf8.DecodeRuneInString(s[i+n:])
+			if i == 0 {
+				prev = ' '
+			}
+			if i+n == len(s) {
+				next = ' '
+			}
+			left := !unicode.IsSpace(next) && (!isPunct(next) || unicode.IsSpace(prev) || isPunct(prev))
+			right := !unicode.IsSpace(prev) && (!isPunct(prev) || unicode.IsSpace(next) || isPunct(next))
+			t := &mdInline{delim: c, n: n, orig: n, open: left, close: right}
+			if c == '_' {
+				t.open = left && (!right || isPunct(prev))
+				t.close = right && (!left || isPunct(next))
+			}
+			toks = append(toks, t)
+			i += n
+		case '!':
+			if i+1 < len(s) && s[i+1] == '[' {
+				flush()
+				toks = append(toks, &mdInline{html: "![", bracket: true, image: true, active: true, pos: i + 2})
+				i += 2
+				continue
+			}
+			text.WriteByte(c)
+			i++
+		case '[':
+			flush()
+			toks = append(toks, &mdInline{html: "[", bracket: true, active: true, pos: i + 1})
+			i++
+		case ']':
+			flush()
+			o := len(toks) - 1
+			for ; o >= 0 && !toks[o].bracket; o-- {
+			}
+			if o < 0 {
+				text.WriteByte(c)
+				i++
+				continue
+			}
+			op := toks[o]
+			op.bracket = false
+			link, end, ok := m.link(s, op.pos, i)
+			if !op.active || !ok {
+				text.WriteByte(c)
+				i++
+				continue
+			}
+			content := toks[o+1:]
+			emphasis(content)
+			var inner strings.Builder
+			for _, t := range content {
+				inner.WriteString(t.String())
+			}
+			title := ""
+			if link.title != "" {
+				title = ` title="` + mdEscape.Replace(link.title) + `"`
+			}
+			var h string
+			if op.image {
+				h = `<img src="` + escapeURL(link.dest) + `" alt="` + stripTags(inner.String()) + `"` + title + ` />`
+			} else {
+				h = `<a href="` + escapeURL(link.dest) + `"` + title + `>` + inner.String() + `</a>`
+				// links may not contain other links
+				for _, t := range toks[:o] {
+					if t.bracket && !t.image {
+						t.active = false
+					}
+				}
+			}
+			toks = append(toks[:o], &mdInline{html: h})
+			i = end
+		case '<':
+			if a := mdAutolink.FindStringSubmatch(s[i:]); a != nil {
+				text.WriteString(`<a href="` + escapeURL(a[1]) + `">` + mdEscape.Replace(a[1]) + `</a>`)
+				i += len(a[0])
+			} else if a := mdEmail.FindStringSubmatch(s[i:]); a != nil {
+				text.WriteString(`<a href="mailto:` + escapeURL(a[1]) + `">` + mdEscape.Replace(a[1]) + `</a>`)
+				i += len(a[0])
+			} else if r := mdRawHTML.FindString(s[i:]); r != "" {
+				text.WriteString(r)
+				i += len(r)
+			} else {
+				text.WriteString("&lt;")
+				i++
+			}
+		case '&':
+			if e := mdEntity.FindString(s[i:]); e != "" {
+				text.WriteString(e)
+				i += len(e)
+			} else {
+				text.WriteString("&amp;")
+				i++
+			}
+		default:
+			// copy up to the next special character
+			e := i + 1
+			for e < len(s) && strings.IndexByte("\\\n`*_![]<&", s[e]) < 0 {
+				e++
+			}
+			text.WriteString(mdEscape.Replace(s[i:e]))
+			i = e
+		}
+	}
+	flush()
+	emphasis(toks)
+	var out strings.Builder
+	for _, t := range toks {
+		out.WriteString(t.String())
+	}
+	return out.String()
+}
+
+// link parses the destination of a link whose text is s[start:end], s[end] is the closing bracket
+// returns the link and the offset after it
+func (m *markdown) link(s string, start, end int) (l mdLink, next int, ok bool) {
+	p := end + 1
+	if p < len(s) && s[p] == '(' {
+		if l, next, ok = inlineLink(s, p); ok {
+			return
+		}
+	}
+	label := s[start:end]
+	next = p
+	if p < len(s) && s[p] == '[' {
+		if e := strings.IndexByte(s[p:], ']'); e > 0 {
+			if e > 1 {
+				label = s[p+1 : p+e]
+			}
+			next = p + e + 1
+		}
+	}
+	l, ok = m.refs[normalizeLabel(label)]
+	return
+}
+
+// inlineLink parses (destination "title") at s[p]
+func inlineLink(s string, p int) (l mdLink, next int, ok bool) {
+	p++
+	skip := func() {
+		for p < len(s) && (s[p] == ' ' || s[p] == '\t' || s[p] == '\n') {
+			p++
+		}
+	}
+	skip()
+	if p < len(s) && s[p] == '<' {
+		e := strings.IndexAny(s[p+1:], "<>\n")
+		if e < 0 || s[p+1+e] != '>' {
+			return
+		}
+		l.dest = s[p+1 : p+1+e]
+		p += e + 2
+	} else {
+		start, depth := p, 0
+		for ; p < len(s); p++ {
+			c := s[p]
+			if c == '\\' && p+1 < len(s) && isASCIIPunct(s[p+1]) {
+				p++
+				continue
+			}
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			} else if c <= ' ' {
+				break
+			}
+		}
+		l.dest = s[start:p]
+	}
+	before := p
+	skip()
+	if p < len(s) && p > before && (s[p] == '"' || s[p] == '\'' || s[p] == '(') {
+		closer := s[p]
+		if closer == '(' {
+			closer = ')'
+		}
+		e := p + 1
+		for ; e < len(s) && s[e] != closer; e++ {
+			if s[e] == '\\' {
+				e++
+			}
+		}
+		if e >= len(s) {
+			return
+		}
+		l.title = unescapeMD(s[p+1 : e])
+		p = e + 1
+		skip()
+	}
+	if p >= len(s) || s[p] != ')' {
+		return
+	}
+	l.dest = unescapeMD(l.dest)
+	return l, p + 1, true
+}
+
+// emphasis matches the delimiter runs in toks into <em> and <strong>
+func emphasis(toks []*mdInline) {
+	for c, closer := range toks {
+		if closer.delim == 0 || !closer.close {
+			continue
+		}
+		for closer.n > 0 {
+			o := c - 1
+			for ; o >= 0; o-- {
+				t := toks[o]
+				if t.delim == closer.delim && t.open && t.n > 0 {
+					// a run that can both open and close must not match one whose length makes a multiple of 3
+					if (t.close || closer.open) && (t.orig+closer.orig)%3 == 0 && !(t.orig%3 == 0 && closer.orig%3 == 0) {
+						continue
+					}
+					break
+				}
+			}
+			if o < 0 {
+				break
+			}
+			opener := toks[o]
+			use, tag := 1, "em"
+			if opener.n >= 2 && closer.n >= 2 {
+				use, tag = 2, "strong"
+			}
+			opener.n -= use
+			closer.n -= use
+			opener.post = "<" + tag + ">" + opener.post
+			closer.pre += "</" + tag + ">"
+			for _, t := range toks[o+1 : c] {
+				t.literal()
+			}
+		}
+	}
+	for _, t := range toks {
+		t.literal()
+	}
+}
+
+// runLen returns the length of the run of s[i] starting at i
+func runLen(s string, i int) int {
+	n := 1
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+// closingTicks returns the offset of the next run of exactly n backticks at or after i, or -1
+func closingTicks(s string, i, n int) int {
+	for i < len(s) {
+		e := strings.IndexByte(s[i:], '`')
+		if e < 0 {
+			return -1
+		}
+		i += e
+		r := runLen(s, i)
+		if r == n {
+			return i
+		}
+		i += r
+	}
+	return -1
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+var mdEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+func stripTags(s string) string {
+	return htmlTags.ReplaceAllString(s, "")
+}
+
+// escapeURL percent encodes the characters not allowed in a URL and escapes it for an attribute
+func escapeURL(u string) string {
+	var b strings.Builder
+	for i := 0; i < len(u); i++ {
+		c := u[i]
+		if c < 128 && (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-._~:/?#[]@!$&'()*+,;=%", c) >= 0) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString("%" + strings.ToUpper(strconv.FormatUint(uint64(c)|0x100, 16)[1:]))
+	}
+	return mdEscape.Replace(b.String())
+}
@@ -0,0 +1,154 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/goauto"
+)
+
+func TestMarkdown(t *testing.T) {
+	cases := []struct{ in, out string }{
+		{"# Hi *there*", "<h1>Hi <em>there</em></h1>\n"},
+		{"a\nb", "<p>a\nb</p>\n"},
+		{"a  \nb", "<p>a<br />\nb</p>\n"},
+		{"Title\n===\n\nSub\n---", "<h1>Title</h1>\n<h2>Sub</h2>\n"},
+		{"***a***", "<p><em><strong>a</strong></em></p>\n"},
+		{"**a*", "<p>*<em>a</em></p>\n"},
+		{"foo_bar_baz", "<p>foo_bar_baz</p>\n"},
+		{"*a **b** c*", "<p><em>a <strong>b</strong> c</em></p>\n"},
+		{"`a <b>`", "<p><code>a &lt;b&gt;</code></p>\n"},
+		{"[x](/u \"T\")", "<p><a href=\"/u\" title=\"T\">x</a></p>\n"},
+		{"![a *b*](i.png)", "<p><img src=\"i.png\" alt=\"a b\" /></p>\n"},
+		{"[x][r]\n\n[r]: /url", "<p><a href=\"/url\">x</a></p>\n"},
+		{"[r]\n\n[R]: /url 'T'", "<p><a href=\"/url\" title=\"T\">r</a></p>\n"},
+		{"<http://a.b?x=1&y>", "<p><a href=\"http://a.b?x=1&amp;y\">http://a.b?x=1&amp;y</a></p>\n"},
+		{"- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"- a\n\n- b", "<ul>\n<li>\n<p>a</p>\n</li>\n<li>\n<p>b</p>\n</li>\n</ul>\n"},
+		{"1. a\n2. b\n   - c", "<ol>\n<li>a</li>\n<li>b\n<ul>\n<li>c</li>\n</ul>\n</li>\n</ol>\n"},
+		{"3) a", "<ol start=\"3\">\n<li>a</li>\n</ol>\n"},
+		{"> a\nb\n> c", "<blockquote>\n<p>a\nb\nc</p>\n</blockquote>\n"},
+		{"```go\nx := 1 < 2\n```", "<pre><code class=\"language-go\">x := 1 &lt; 2\n</code></pre>\n"},
+		{"    code\n\n    more", "<pre><code>code\n\nmore\n</code></pre>\n"},
+		{"***", "<hr />\n"},
+		{"<div>\n*a*\n</div>", "<div>\n*a*\n</div>\n"},
+		{"a <span>b</span> &copy; & c", "<p>a <span>b</span> &copy; &amp; c</p>\n"},
+		{"| a | b |\n|:--|--:|\n| 1 | 2 |\n| 3 \\| 4 |", "<table>\n<thead>\n<tr>\n<th align=\"left\">a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td align=\"left\">1</td>\n<td align=\"right\">2</td>\n</tr>\n<tr>\n<td align=\"left\">3 | 4</td>\n<td align=\"right\"></td>\n</tr>\n</tbody>\n</table>\n"},
+		{"- a\n\n  b\n- c", "<ul>\n<li>\n<p>a</p>\n<p>b</p>\n</li>\n<li>\n<p>c</p>\n</li>\n</ul>\n"},
+		{"-\n  foo\n- bar", "<ul>\n<li>foo</li>\n<li>bar</li>\n</ul>\n"},
+		{"- ```\n  b\n\n\n  ```\n- c", "<ul>\n<li>\n<pre><code>b\n\n\n</code></pre>\n</li>\n<li>c</li>\n</ul>\n"},
+		{"a\n- b", "<p>a</p>\n<ul>\n<li>b</li>\n</ul>\n"},
+		{"a\n2. b", "<p>a\n2. b</p>\n"},
+		{"\\*a\\* [b\\]", "<p>*a* [b]</p>\n"},
+		{"[a [b](/x)](/y)", "<p>[a <a href=\"/x\">b</a>](/y)</p>\n"},
+		{"*a _b* c_", "<p><em>a _b</em> c_</p>\n"},
+		{"x\\\ny", "<p>x<br />\ny</p>\n"},
+		{"[a](<b c>)", "<p><a href=\"b%20c\">a</a></p>\n"},
+		{"## Heading ##", "<h2>Heading</h2>\n"},
+		{"\t- a", "<pre><code>- a\n</code></pre>\n"},
+		{"__a__ _b_", "<p><strong>a</strong> <em>b</em></p>\n"},
+		{"* * *\n- x", "<hr />\n<ul>\n<li>x</li>\n</ul>\n"},
+	}
+	for _, c := range cases {
+		if got := string(Markdown([]byte(c.in))); got != c.out {
+			t.Errorf("Markdown(%q)\n got %q\nwant %q", c.in, got, c.out)
+		}
+	}
+}
+
+func TestFrontMatter(t *testing.T) {
+	meta, body := frontMatter([]byte("---\nTitle: \"Getting: Started\"\ndate: 2015-06-01\n# comment\n---\n# Body\n"))
+	if meta["title"] != "Getting: Started" || meta["date"] != "2015-06-01" || len(meta) != 2 {
+		t.Errorf("Unexpected meta %v", meta)
+	}
+	if string(body) != "# Body\n" {
+		t.Errorf("Unexpected body %q", body)
+	}
+	// not closed
+	if meta, body = frontMatter([]byte("---\na: b\n")); len(meta) != 0 || string(body) != "---\na: b\n" {
+		t.Errorf("Expected no front matter got %v %q", meta, body)
+	}
+}
+
+func TestMarkdownTask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"docs/intro.md":   "---\ntitle: Intro\ndate: 2015-06-01\n---\nHello *world*\n",
+		"docs/guide.md":   "# The Guide\n\n| a | b |\n|---|---|\n| 1 | 2 |\n",
+		"layout.html":     "<title>{{.Title}}</title>{{with .Meta.date}}{{upper .}}{{end}}{{.Content}}",
+		"index.html.tmpl": "{{range .Pages}}{{.URL}}={{.Title}};{{end}}",
+	})
+	p := func(n string) string { return filepath.Join(dir, filepath.FromSlash(n)) }
+	html := func(f string) string {
+		return strings.Replace(strings.Replace(f, "docs", "site", 1), ".md", ".html", 1)
+	}
+
+	mt := NewMarkdownTask(html, p("layout.html"))
+	mt.Funcs = map[string]interface{}{"upper": strings.ToUpper}
+	mt.Index = p("site/index.html")
+	mt.IndexLayout = p("index.html.tmpl")
+	mt.Pages = []string{p("docs/*.md")}
+	var out bytes.Buffer
+	run := func(src string) {
+		info := &goauto.TaskInfo{Src: src, Tout: &out, Terr: ioutil.Discard, Verbose: true}
+		if err := mt.Run(info); err != nil {
+			t.Fatal(err)
+		}
+		if info.Target != html(src) {
+			t.Errorf("Expected Target %v got %v", html(src), info.Target)
+		}
+	}
+	read := func(f string) string {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	run(p("docs/intro.md"))
+	if got, want := read(p("site/intro.html")), "<title>Intro</title>2015-06-01<p>Hello <em>world</em></p>\n"; got != want {
+		t.Errorf("Expected %q got %q", want, got)
+	}
+	if got, want := read(p("site/index.html")), "intro.html=Intro;guide.html=The Guide;"; got != want {
+		t.Errorf("Expected index %q got %q", want, got)
+	}
+
+	// the index is only written when it changes
+	out.Reset()
+	run(p("docs/guide.md"))
+	if !strings.Contains(read(p("site/guide.html")), "<table>") {
+		t.Error("Expected a table")
+	}
+	if strings.Contains(out.String(), "index.html") {
+		t.Errorf("Expected the index to be unchanged, got %q", out.String())
+	}
+
+	// added and removed pages
+	writeFiles(t, dir, map[string]string{"docs/new.md": "---\ndate: 2016-01-01\n---\nNo heading\n"})
+	run(p("docs/new.md"))
+	if got, want := read(p("site/index.html")), "new.html=new;intro.html=Intro;guide.html=The Guide;"; got != want {
+		t.Errorf("Expected index %q got %q", want, got)
+	}
+	if err = os.Remove(p("docs/intro.md")); err != nil {
+		t.Fatal(err)
+	}
+	run(p("docs/intro.md"))
+	if _, err = os.Stat(p("site/intro.html")); !os.IsNotExist(err) {
+		t.Error("Expected intro.html to be removed")
+	}
+	if got, want := read(p("site/index.html")), "new.html=new;guide.html=The Guide;"; got != want {
+		t.Errorf("Expected index %q got %q", want, got)
+	}
+}
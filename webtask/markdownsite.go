@@ -0,0 +1,296 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/goauto"
+)
+
+// A Page is a Markdown file rendered by a MarkdownTask, it is passed to the Layout
+type Page struct {
+	Title   string            // The title front matter, the first heading or the file name
+	Meta    map[string]string // Front matter, keys are lower case
+	Src     string            // Markdown file, absolute path
+	Target  string            // HTML file
+	URL     string            // Target relative to the Index, slash separated
+	Content template.HTML
+	mod     time.Time
+}
+
+// A PageIndex is passed to the IndexLayout
+// Pages are sorted newest first by their date front matter, i.e. date: 2015-06-01, then by Title
+type PageIndex struct {
+	Title string
+	Pages []*Page
+}
+
+// A MarkdownTask renders Markdown files to HTML pages (See Markdown)
+// A file may start with front matter of key: value lines between --- lines
+//
+//	---
+//	title: Getting Started
+//	date: 2015-06-01
+//	---
+//
+// With an Index the pages matching Pages are listed in an index page that is
+// regenerated when a page is added, removed or retitled
+type MarkdownTask struct {
+	Transform   goauto.Transformer
+	Layout      string // html/template file executed with each Page, a plain page is used if empty
+	Funcs       template.FuncMap
+	Index       string   // Optional HTML file listing the Pages
+	IndexLayout string   // html/template file executed with the PageIndex, a plain list is used if empty
+	IndexTitle  string   // Title of the index, defaults to Index
+	Pages       []string // Glob patterns of the Markdown files listed in the Index, i.e. docs/*.md
+	mu          sync.Mutex
+	pages       map[string]*Page
+	index       string // contents of the last index written
+}
+
+// NewMarkdownTask returns a MarkdownTask that renders to the Transformer target using the layout template file
+// layout may be empty
+func NewMarkdownTask(t goauto.Transformer, layout string) *MarkdownTask {
+	return &MarkdownTask{Transform: t, Layout: layout}
+}
+
+var pageLayout = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+{{.Content}}</body>
+</html>
+`))
+
+var indexLayout = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+{{range .Pages}}<li><a href="{{.URL}}">{{.Title}}</a>{{with .Meta.date}} {{.}}{{end}}</li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// Run will render TaskInfo.Src to the Transformer target and update the Index
+// If TaskInfo.Src was removed the target is removed
+func (mt *MarkdownTask) Run(info *goauto.TaskInfo) (err error) {
+	info.Target = mt.Transform(info.Src)
+	info.Buf.Reset()
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	if _, err = os.Stat(info.Src); os.IsNotExist(err) {
+		if err = os.Remove(info.Target); err != nil && !os.IsNotExist(err) {
+			return
+		}
+		if info.Verbose {
+			fmt.Fprintf(info.Tout, "<< Removed %v\n", info.Target)
+		}
+		return mt.writeIndex(info)
+	}
+	src, err := filepath.Abs(info.Src)
+	if err != nil {
+		return
+	}
+	p, err := mt.page(src, info.Target)
+	if err != nil {
+		return
+	}
+	if mt.pages == nil {
+		mt.pages = make(map[string]*Page)
+	}
+	mt.pages[src] = p
+	t, err := mt.layout(mt.Layout, pageLayout)
+	if err != nil {
+		return
+	}
+	if err = t.Execute(&info.Buf, p); err != nil {
+		return
+	}
+	if err = writeFileAll(info.Target, info.Buf.Bytes()); err != nil {
+		return
+	}
+	info.Buf.Reset()
+	if info.Verbose {
+		fmt.Fprintf(info.Tout, "<< %v -> %v\n", filepath.Base(info.Src), info.Target)
+	}
+	return mt.writeIndex(info)
+}
+
+// page reads and renders src
+func (mt *MarkdownTask) page(src, target string) (*Page, error) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	meta, body := frontMatter(b)
+	content := Markdown(body)
+	p := &Page{Title: meta["title"], Meta: meta, Src: src, Target: target, Content: template.HTML(content), mod: fi.ModTime()}
+	if p.Title == "" {
+		if h := firstHeading.FindSubmatch(content); h != nil {
+			p.Title = html.UnescapeString(stripTags(string(h[1])))
+		}
+	}
+	if p.Title == "" {
+		p.Title = strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	}
+	p.URL = filepath.ToSlash(filepath.Base(target))
+	if mt.Index != "" {
+		index, err := filepath.Abs(mt.Index)
+		if err != nil {
+			return nil, err
+		}
+		if t, err := filepath.Abs(target); err == nil {
+			if rel, err := filepath.Rel(filepath.Dir(index), t); err == nil {
+				p.URL = filepath.ToSlash(rel)
+			}
+		}
+	}
+	return p, nil
+}
+
+var firstHeading = regexp.MustCompile(`<h1>(.*?)</h1>`)
+
+// layout parses the template file or returns def if file is empty
+func (mt *MarkdownTask) layout(file string, def *template.Template) (*template.Template, error) {
+	if file == "" {
+		return def, nil
+	}
+	t, err := template.New(filepath.Base(file)).Funcs(mt.Funcs).ParseFiles(file)
+	if err != nil {
+		return nil, templateError(err, []string{file})
+	}
+	return t, nil
+}
+
+// writeIndex writes the Index if the list of pages changed
+func (mt *MarkdownTask) writeIndex(info *goauto.TaskInfo) error {
+	if mt.Index == "" {
+		return nil
+	}
+	files, err := globAll(mt.Pages)
+	if err != nil {
+		return err
+	}
+	idx := &PageIndex{Title: mt.IndexTitle}
+	if idx.Title == "" {
+		idx.Title = "Index"
+	}
+	pages := make(map[string]*Page)
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		p := mt.pages[f]
+		if p == nil || !p.mod.Equal(fi.ModTime()) {
+			if p, err = mt.page(f, mt.Transform(f)); err != nil {
+				return err
+			}
+		}
+		pages[f] = p
+		idx.Pages = append(idx.Pages, p)
+	}
+	mt.pages = pages
+	sort.Sort(byDate(idx.Pages))
+
+	t, err := mt.layout(mt.IndexLayout, indexLayout)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, idx); err != nil {
+		return err
+	}
+	if _, err = os.Stat(mt.Index); err == nil && buf.String() == mt.index {
+		return nil
+	}
+	if err = writeFileAll(mt.Index, buf.Bytes()); err != nil {
+		return err
+	}
+	mt.index = buf.String()
+	if info.Verbose {
+		fmt.Fprintf(info.Tout, "<< %v %v pages\n", mt.Index, len(idx.Pages))
+	}
+	return nil
+}
+
+// writeFileAll writes b to file creating the directory if needed
+func writeFileAll(file string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0644)
+}
+
+// byDate sorts Pages newest first, pages without a date last
+type byDate []*Page
+
+func (p byDate) Len() int      { return len(p) }
+func (p byDate) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byDate) Less(i, j int) bool {
+	if di, dj := p[i].Meta["date"], p[j].Meta["date"]; di != dj {
+		return di > dj
+	}
+	if p[i].Title != p[j].Title {
+		return p[i].Title < p[j].Title
+	}
+	return p[i].URL < p[j].URL
+}
+
+// frontMatter splits the key: value lines between --- lines at the start of src from the Markdown
+// Keys are lower cased and quotes around values are removed
+func frontMatter(src []byte) (map[string]string, []byte) {
+	meta := make(map[string]string)
+	s := strings.Replace(string(src), "\r\n", "\n", -1)
+	if !strings.HasPrefix(s, "---\n") {
+		return meta, src
+	}
+	lines := strings.SplitAfter(s[4:], "\n")
+	n := 4
+	for i, l := range lines {
+		n += len(l)
+		l = strings.TrimSpace(l)
+		if l == "---" || l == "..." {
+			for _, kv := range lines[:i] {
+				c := strings.Index(kv, ":")
+				if c < 0 || strings.HasPrefix(strings.TrimSpace(kv), "#") {
+					continue
+				}
+				v := strings.TrimSpace(kv[c+1:])
+				if len(v) > 1 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+					v = v[1 : len(v)-1]
+				}
+				meta[strings.ToLower(strings.TrimSpace(kv[:c]))] = v
+			}
+			return meta, []byte(s[n:])
+		}
+	}
+	return meta, src // not closed
+}
@@ -0,0 +1,555 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/dshills/goauto"
+)
+
+// MinTransformer is a Transformer for minified files, app.css becomes app.min.css
+func MinTransformer(f string) string {
+	ext := filepath.Ext(f)
+	return strings.TrimSuffix(f, ext) + ".min" + ext
+}
+
+// MinifyCSS returns src without comments and unneeded whitespace
+// Comments starting with /*! are kept
+func MinifyCSS(src []byte) []byte {
+	e := new(emitter)
+	minifyCSS(src, e)
+	return e.out
+}
+
+// MinifyJS returns src without comments and unneeded whitespace
+// Line breaks that automatic semicolon insertion may depend on are kept
+// Comments starting with /*! are kept
+func MinifyJS(src []byte) []byte {
+	e := new(emitter)
+	minifyJS(src, e)
+	return e.out
+}
+
+// MinifyHTML returns src without comments and with whitespace collapsed
+// Whitespace next to block level tags is removed, pre and textarea contents are kept,
+// inline scripts and styles are minified
+func MinifyHTML(src []byte) []byte {
+	return minifyHTML(src)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// quoted returns the end of the string starting with the quote at i
+func quoted(src []byte, i int) int {
+	q := src[i]
+	for i++; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case q:
+			return i + 1
+		case '\n':
+			return i // unterminated
+		}
+	}
+	return len(src)
+}
+
+// comment returns the end of the block comment starting at i
+func comment(src []byte, i int) int {
+	end := bytes.Index(src[i+2:], []byte("*/"))
+	if end < 0 {
+		return len(src)
+	}
+	return i + 2 + end + 2
+}
+
+func minifyCSS(src []byte, e *emitter) {
+	ws, semi := false, false
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case isSpace(c):
+			ws = true
+			i++
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := comment(src, i)
+			if i+2 < len(src) && src[i+2] == '!' {
+				e.mark(i)
+				e.write(src[i:end])
+				ws = false
+			} else {
+				ws = true
+			}
+			i = end
+			continue
+		case c == ';':
+			semi = true // dropped before }
+			ws = false
+			i++
+			continue
+		}
+
+		if semi && c != '}' {
+			e.writeByte(';')
+		}
+		semi = false
+		if ws && cssSpace(e.last(), c) {
+			e.writeByte(' ')
+		}
+		ws = false
+		end := i + 1
+		switch {
+		case c == '"' || c == '\'':
+			end = quoted(src, i)
+		case bytes.HasPrefix(src[i:], []byte("url(")):
+			// unquoted urls may contain // and other comment like text
+			if j := bytes.IndexByte(src[i:], ')'); j > 0 {
+				end = i + j + 1
+			}
+		}
+		e.mark(i)
+		e.write(src[i:end])
+		i = end
+	}
+	if semi {
+		e.writeByte(';')
+	}
+}
+
+// cssSpace reports whether whitespace between prev and next is needed
+func cssSpace(prev, next byte) bool {
+	if prev == 0 || strings.IndexByte("{};,>~:(/", prev) >= 0 {
+		return false
+	}
+	// a space before ( is needed in media queries, before : in selectors and around + and - in calc
+	return strings.IndexByte("{};,>~)!/", next) < 0
+}
+
+func isIdent(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$' || c == '#' || c == '\\' || c >= 0x80
+}
+
+// keywords after which a / starts a regular expression
+var regexKeywords = map[string]bool{
+	"return": true, "typeof": true, "case": true, "do": true, "else": true, "in": true,
+	"instanceof": true, "new": true, "void": true, "delete": true, "throw": true, "yield": true, "await": true,
+}
+
+func minifyJS(src []byte, e *emitter) {
+	ws, nl := false, false
+	word := ""
+	i := 0
+	if bytes.HasPrefix(src, []byte("#!")) {
+		for i < len(src) && src[i] != '\n' {
+			i++
+		}
+		e.mark(0)
+		e.write(src[:i])
+	}
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '\n':
+			ws, nl = true, true
+			i++
+			continue
+		case isSpace(c):
+			ws = true
+			i++
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			ws = true
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := comment(src, i)
+			if i+2 < len(src) && src[i+2] == '!' {
+				if e.last() != 0 && e.last() != '\n' {
+					e.writeByte('\n')
+				}
+				e.mark(i)
+				e.write(src[i:end])
+				e.writeByte('\n')
+				ws, nl = false, false
+			} else {
+				ws = true
+				nl = nl || bytes.IndexByte(src[i:end], '\n') >= 0
+			}
+			i = end
+			continue
+		}
+
+		prev := e.last()
+		if ws {
+			if sep := jsSpace(prev, c, nl); sep != 0 {
+				e.writeByte(sep)
+			}
+		}
+		ws, nl = false, false
+
+		end := i + 1
+		isWord := false
+		switch {
+		case c == '"' || c == '\'':
+			end = quoted(src, i)
+		case c == '`':
+			end = templateLit(src, i)
+		case c == '/' && regexAllowed(prev, word):
+			end = regex(src, i)
+		case isIdent(c):
+			for end < len(src) && isIdent(src[end]) {
+				end++
+			}
+			isWord = true
+		}
+		if isWord {
+			word = string(src[i:end])
+		} else {
+			word = ""
+		}
+		e.mark(i)
+		e.write(src[i:end])
+		i = end
+	}
+}
+
+// jsSpace returns the separator needed in place of whitespace between prev and next
+// nl is set if the whitespace contained a line break
+func jsSpace(prev, next byte, nl bool) byte {
+	if prev == 0 || prev == '\n' {
+		return 0
+	}
+	sep := byte(' ')
+	if nl {
+		sep = '\n'
+	}
+	switch {
+	case isIdent(prev) && isIdent(next):
+		return sep
+	case (prev == '+' || prev == '-') && next == prev:
+		return ' '
+	case prev >= '0' && prev <= '9' && next == '.':
+		return ' ' // 1 .toString()
+	case nl && (isIdent(prev) || strings.IndexByte(")]}'\"`+-/", prev) >= 0) &&
+		(isIdent(next) || strings.IndexByte("([{'\"`+-!~/", next) >= 0):
+		return '\n' // automatic semicolon insertion may depend on it
+	}
+	return 0
+}
+
+func regexAllowed(prev byte, word string) bool {
+	if word != "" {
+		return regexKeywords[word]
+	}
+	return prev == 0 || strings.IndexByte("(,=:[!&|?{};+-*%<>~^\n", prev) >= 0
+}
+
+// regex returns the end of the regular expression literal starting at i
+func regex(src []byte, i int) int {
+	class := false
+	for i++; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == '\\':
+			i++
+		case c == '[':
+			class = true
+		case c == ']':
+			class = false
+		case c == '/' && !class:
+			i++
+			for i < len(src) && isIdent(src[i]) {
+				i++ // flags
+			}
+			return i
+		case c == '\n':
+			return i
+		}
+	}
+	return len(src)
+}
+
+// templateLit returns the end of the template literal starting at i
+func templateLit(src []byte, i int) int {
+	for i++; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '`':
+			return i + 1
+		case '$':
+			if i+1 < len(src) && src[i+1] == '{' {
+				i = templateExpr(src, i+2) - 1
+			}
+		}
+	}
+	return len(src)
+}
+
+// templateExpr returns the end of the ${} expression whose contents start at i
+func templateExpr(src []byte, i int) int {
+	depth := 1
+	for i < len(src) {
+		switch src[i] {
+		case '"', '\'':
+			i = quoted(src, i)
+			continue
+		case '`':
+			i = templateLit(src, i)
+			continue
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+		i++
+	}
+	return len(src)
+}
+
+// tags that whitespace around can be removed from
+var blockTags = map[string]bool{
+	"html": true, "head": true, "body": true, "title": true, "meta": true, "link": true, "script": true, "style": true,
+	"div": true, "p": true, "ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "td": true, "th": true,
+	"section": true, "article": true, "header": true, "footer": true, "nav": true, "aside": true, "main": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "form": true, "fieldset": true,
+	"hr": true, "br": true, "figure": true, "figcaption": true, "blockquote": true, "!doctype": true, "option": true,
+	"pre": true, "textarea": true, "noscript": true, "template": true,
+}
+
+// tagName returns the lower case name of the tag starting at i and whether it is a closing tag
+func tagName(src []byte, i int) (string, bool) {
+	i++
+	closing := i < len(src) && src[i] == '/'
+	if closing {
+		i++
+	}
+	j := i
+	for j < len(src) && !isSpace(src[j]) && src[j] != '>' && src[j] != '/' {
+		j++
+	}
+	return strings.ToLower(string(src[i:j])), closing
+}
+
+// tagEnd returns the end of the tag starting at i
+// Quotes only delimit attribute values directly after =, an unterminated value ends at the next >
+func tagEnd(src []byte, i int) int {
+	start := i
+	for i++; i < len(src); i++ {
+		switch src[i] {
+		case '"', '\'':
+			if !afterEquals(src[start:i]) {
+				continue
+			}
+			q := bytes.IndexByte(src[i+1:], src[i])
+			if q < 0 {
+				if gt := bytes.IndexByte(src[i:], '>'); gt >= 0 {
+					return i + gt + 1
+				}
+				return len(src)
+			}
+			i += q + 1
+		case '>':
+			return i + 1
+		}
+	}
+	return len(src)
+}
+
+// afterEquals returns true if the last non space byte of b is =
+func afterEquals(b []byte) bool {
+	for j := len(b) - 1; j >= 0; j-- {
+		if !isSpace(b[j]) {
+			return b[j] == '='
+		}
+	}
+	return false
+}
+
+// minifyTag collapses the whitespace between attributes
+// A tag with an unterminated quoted value is returned unchanged
+func minifyTag(tag []byte) []byte {
+	var out []byte
+	ws := false
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		switch {
+		case (c == '"' || c == '\'') && afterEquals(out):
+			end := bytes.IndexByte(tag[i+1:], c)
+			if end < 0 {
+				return tag
+			}
+			out = append(out, tag[i:i+end+2]...)
+			i += end + 1
+			ws = false
+		case isSpace(c):
+			ws = true
+		case c == '>' || (c == '/' && i+1 < len(tag) && tag[i+1] == '>'):
+			out = append(out, tag[i:]...)
+			return out
+		default:
+			if ws && c != '=' && out[len(out)-1] != '=' {
+				out = append(out, ' ')
+			}
+			out = append(out, c)
+			ws = false
+		}
+	}
+	return out
+}
+
+func minifyHTML(src []byte) []byte {
+	var out []byte
+	ws, afterBlock := false, true
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case isSpace(c):
+			ws = true
+			i++
+		case bytes.HasPrefix(src[i:], []byte("<!--")):
+			end := bytes.Index(src[i+4:], []byte("-->"))
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + 7
+			}
+			if bytes.HasPrefix(src[i+4:], []byte("[if")) || bytes.HasPrefix(src[i+4:], []byte("!")) {
+				out = append(out, src[i:end]...) // conditional and kept comments
+			}
+			i = end
+		case c == '<' && i+1 < len(src) && (src[i+1] == '/' || src[i+1] == '!' || src[i+1] >= 'a' && src[i+1] <= 'z' || src[i+1] >= 'A' && src[i+1] <= 'Z'):
+			name, closing := tagName(src, i)
+			block := blockTags[name]
+			if ws && !block && !afterBlock {
+				out = append(out, ' ')
+			}
+			ws = false
+			end := tagEnd(src, i)
+			tag := src[i:end]
+			out = append(out, minifyTag(tag)...)
+			i = end
+			afterBlock = block
+			if closing {
+				continue
+			}
+			switch name {
+			case "script", "style", "pre", "textarea":
+				j := indexFold(src[i:], "</"+name)
+				if j < 0 {
+					j = len(src) - i
+				}
+				out = append(out, rawText(name, tag, src[i:i+j])...)
+				i += j
+			}
+		default:
+			if ws && !afterBlock {
+				out = append(out, ' ')
+			}
+			ws, afterBlock = false, false
+			out = append(out, c)
+			i++
+		}
+	}
+	return out
+}
+
+// rawText minifies the contents of a script or style element, other contents are kept as is
+func rawText(name string, tag, text []byte) []byte {
+	lower := bytes.ToLower(tag)
+	switch {
+	case name == "style":
+		return MinifyCSS(text)
+	case name == "script" && (!bytes.Contains(lower, []byte("type=")) || bytes.Contains(lower, []byte("javascript")) || bytes.Contains(lower, []byte("module"))):
+		return MinifyJS(text)
+	}
+	return text
+}
+
+// indexFold returns the index of the first case insensitive instance of s in b or -1
+func indexFold(b []byte, s string) int {
+	return bytes.Index(bytes.ToLower(b), []byte(strings.ToLower(s)))
+}
+
+// A MinifyTask minifies a CSS, JavaScript or HTML file TaskInfo.Src to Transform(TaskInfo.Src)
+// i.e. NewMinifyCSSTask(MinTransformer) writes app.css to app.min.css
+// With SourceMap set a CSS or JavaScript Target gets a Target.map source map
+type MinifyTask struct {
+	Transform goauto.Transformer
+	SourceMap bool
+	minify    func(src []byte, e *emitter)
+}
+
+// NewMinifyCSSTask returns a MinifyTask for CSS files
+func NewMinifyCSSTask(t goauto.Transformer) *MinifyTask {
+	return &MinifyTask{Transform: t, minify: minifyCSS}
+}
+
+// NewMinifyJSTask returns a MinifyTask for JavaScript files
+func NewMinifyJSTask(t goauto.Transformer) *MinifyTask {
+	return &MinifyTask{Transform: t, minify: minifyJS}
+}
+
+// NewMinifyHTMLTask returns a MinifyTask for HTML files
+// HTML files do not get source maps
+func NewMinifyHTMLTask(t goauto.Transformer) *MinifyTask {
+	return &MinifyTask{Transform: t, minify: func(src []byte, e *emitter) {
+		e.write(minifyHTML(src))
+	}}
+}
+
+// Target returns the Target the task will produce for src
+func (mt *MinifyTask) Target(src string) string {
+	return mt.Transform(src)
+}
+
+// Run will minify the file
+func (mt *MinifyTask) Run(info *goauto.TaskInfo) (err error) {
+	info.Target = mt.Transform(info.Src)
+	info.Buf.Reset()
+	src, err := ioutil.ReadFile(info.Src)
+	if err != nil {
+		return
+	}
+	e := new(emitter)
+	if mt.SourceMap && mapComment(info.Target) != "" {
+		e.sm = new(sourceMap)
+		e.source(src, e.sm.addSource(mapSource(info.Target, info.Src)))
+	}
+	mt.minify(src, e)
+	size := len(e.out)
+
+	if err = e.writeFile(info.Target); err != nil {
+		return
+	}
+	if info.Verbose {
+		saved := 0.0
+		if len(src) > 0 {
+			saved = 100 - float64(size)*100/float64(len(src))
+		}
+		fmt.Fprintf(info.Tout, "<< %v %v -> %v, %.1f%% smaller\n", filepath.Base(info.Target), sizeReport(len(src)), sizeReport(size), saved)
+	}
+	return
+}
+
+// sizeReport formats a file size
+func sizeReport(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%v B", n)
+	}
+	return fmt.Sprintf("%.1f KB", float64(n)/1024)
+}
@@ -0,0 +1,192 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/goauto"
+)
+
+func TestMinifyCSS(t *testing.T) {
+	tests := []struct{ in, out string }{
+		{"a , b > c {\n  color : red ;\n  margin: 0 auto;\n}\n", "a,b>c{color :red;margin:0 auto}"},
+		{"/* gone */ /*! kept */\np{ }", "/*! kept */p{}"},
+		{"div :first-child{width:calc(100% - 2px)}", "div :first-child{width:calc(100% - 2px)}"},
+		{"@media screen and (max-width: 600px) {\n  a { b: c; }\n}", "@media screen and (max-width:600px){a{b:c}}"},
+		{`a{content:" { ; } ";background:url(http://x.com/a.png)}`, `a{content:" { ; } ";background:url(http://x.com/a.png)}`},
+		{"a{color:red !important;;}", "a{color:red!important}"},
+		{"a{font: 12px / 1.5 serif; grid-area: 1 / 2}", "a{font:12px/1.5 serif;grid-area:1/2}"},
+	}
+	for _, tt := range tests {
+		if out := string(MinifyCSS([]byte(tt.in))); out != tt.out {
+			t.Errorf("Expected %q got %q", tt.out, out)
+		}
+	}
+}
+
+func TestMinifyJS(t *testing.T) {
+	tests := []struct{ in, out string }{
+		{"var a = 1 ;\n// comment\nvar b = a + +1;", "var a=1;var b=a+ +1;"},
+		{"let a = 1\nlet b = 2\n", "let a=1\nlet b=2"},
+		{"return /* x */ a", "return a"},
+		{"x = a / b / c; y = /[/]\\/ /g.test(s)", "x=a/b/c;y=/[/]\\/ /g.test(s)"},
+		{"if (x) {\n  return 'a // b'\n}\nfoo()", "if(x){return'a // b'}\nfoo()"},
+		{"s = `a ${ b + `c ${d}` }  e`", "s=`a ${ b + `c ${d}` }  e`"},
+		{"a = 1 .toString(); b = i++ + ++j", "a=1 .toString();b=i++ + ++j"},
+		{"/*! license */\nvar a", "/*! license */\nvar a"},
+		{"a\n(b)", "a\n(b)"},
+		{"a = b\n.c()", "a=b.c()"},
+	}
+	for _, tt := range tests {
+		if out := string(MinifyJS([]byte(tt.in))); out != tt.out {
+			t.Errorf("Expected %q got %q", tt.out, out)
+		}
+	}
+}
+
+func TestMinifyHTML(t *testing.T) {
+	in := `<!DOCTYPE html>
+<html>
+  <head>
+    <!-- gone -->
+    <title> Test </title>
+    <style>
+      body { color : red ; }
+    </style>
+  </head>
+  <body>
+    <p class="a   b"   id = "x">Some   <b>bold</b> <i>text</i></p>
+    <pre>  keep
+   this  </pre>
+    <script>
+      var a = 1 ; // c
+    </script>
+    <script type="text/template"><p>  raw  </p></script>
+  </body>
+</html>
+`
+	out := `<!DOCTYPE html><html><head><title>Test</title><style>body{color :red}</style></head><body><p class="a   b" id="x">Some <b>bold</b> <i>text</i></p><pre>  keep
+   this  </pre><script>var a=1;</script><script type="text/template"><p>  raw  </p></script></body></html>`
+	if s := string(MinifyHTML([]byte(in))); s != out {
+		t.Errorf("Expected %q got %q", out, s)
+	}
+}
+
+func TestMinifyHTMLQuotes(t *testing.T) {
+	tests := map[string]string{
+		// an apostrophe in an unquoted value is not a quote
+		"<img alt=don't   src=x.png> after  <b>x</b>": "<img alt=don't src=x.png> after <b>x</b>",
+		"<p title = 'it\"s'  >a</p>":                  "<p title='it\"s'>a</p>",
+		// an unterminated value is passed through
+		"<img alt=\"broken   src=x.png>  after": "<img alt=\"broken   src=x.png> after",
+		"<p class='x>":                          "<p class='x>",
+	}
+	for in, out := range tests {
+		if s := string(MinifyHTML([]byte(in))); s != out {
+			t.Errorf("MinifyHTML(%q) expected %q got %q", in, out, s)
+		}
+	}
+}
+
+func TestVLQ(t *testing.T) {
+	tests := map[int]string{0: "A", 1: "C", -1: "D", 15: "e", 16: "gB", -17: "jB", 1000: "w+B"}
+	for v, s := range tests {
+		if b := string(vlq(nil, v)); b != s {
+			t.Errorf("Expected %v for %v got %v", s, v, b)
+		}
+	}
+}
+
+func TestMinifyTask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-min")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "app.js")
+	ioutil.WriteFile(src, []byte("var a = 1;\n\nfunction f ( x ) {\n  return x\n}\n"), 0644)
+
+	mt := NewMinifyJSTask(MinTransformer)
+	mt.SourceMap = true
+	var out strings.Builder
+	ti := &goauto.TaskInfo{Src: src, Tout: &out, Terr: ioutil.Discard, Verbose: true}
+	if err = mt.Run(ti); err != nil {
+		t.Fatal(err)
+	}
+	if ti.Target != filepath.Join(dir, "app.min.js") {
+		t.Errorf("Expected app.min.js got %v", ti.Target)
+	}
+	b, _ := ioutil.ReadFile(ti.Target)
+	if s := string(b); s != "var a=1;function f(x){return x}\n//# sourceMappingURL=app.min.js.map\n" {
+		t.Errorf("Unexpected output %q", s)
+	}
+	if !strings.Contains(out.String(), "app.min.js 44 B -> 31 B, 29.5% smaller") {
+		t.Errorf("Expected size report got %q", out.String())
+	}
+
+	b, err = ioutil.ReadFile(ti.Target + ".map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sm struct {
+		Version  int
+		File     string
+		Sources  []string
+		Mappings string
+	}
+	if err = json.Unmarshal(b, &sm); err != nil {
+		t.Fatal(err)
+	}
+	if sm.Version != 3 || sm.File != "app.min.js" || len(sm.Sources) != 1 || sm.Sources[0] != "app.js" {
+		t.Errorf("Unexpected source map %+v", sm)
+	}
+	segs := decodeMappings(sm.Mappings)
+	// function is at generated 0:8 and source 2:0, return at 0:22 and 3:2
+	found := 0
+	for _, s := range segs {
+		if s == (segment{0, 8, 0, 2, 0}) || s == (segment{0, 22, 0, 3, 2}) {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("Unexpected mappings %v", segs)
+	}
+}
+
+// decodeMappings decodes the mappings of a source map
+func decodeMappings(m string) (segs []segment) {
+	var prev segment
+	for line, l := range strings.Split(m, ";") {
+		prev.genCol = 0
+		for _, seg := range strings.Split(l, ",") {
+			if seg == "" {
+				continue
+			}
+			var vals []int
+			v, shift := 0, uint(0)
+			for _, c := range seg {
+				d := strings.IndexRune(base64Digits, c)
+				v |= (d & 31) << shift
+				shift += 5
+				if d&32 == 0 {
+					if v&1 != 0 {
+						vals = append(vals, -(v >> 1))
+					} else {
+						vals = append(vals, v>>1)
+					}
+					v, shift = 0, 0
+				}
+			}
+			prev = segment{line, prev.genCol + vals[0], prev.src + vals[1], prev.srcLine + vals[2], prev.srcCol + vals[3]}
+			segs = append(segs, prev)
+		}
+	}
+	return
+}
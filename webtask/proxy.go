@@ -0,0 +1,220 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dshills/goauto"
+)
+
+const defaultHold = 30 * time.Second
+
+// A Proxy is a development reverse proxy that listens on a stable address in front of a server
+// restarted by a Workflow, such as one run by shelltask.RestartTask
+// Requests are held while a ProxyTask is rebuilding the server and while the server is starting up
+// When the build fails every request gets an error page with the build output
+// A Proxy is a goauto.Service, use Pipeline.Manage to run it with a Pipeline
+type Proxy struct {
+	Addr    string        // Address to listen on, i.e. ":3000"
+	Backend *url.URL      // URL of the server
+	Timeout time.Duration // Time to hold requests, defaults to 30s
+	mu      sync.Mutex
+	ready   chan struct{} // closed when no rebuild is in progress
+	failure []byte        // output of the last failed build
+	bg      server
+	rp      *httputil.ReverseProxy
+	tr      *http.Transport
+}
+
+// NewProxy returns a Proxy listening on addr for the server at backend, i.e. NewProxy(":3000", "http://localhost:8080")
+func NewProxy(addr, backend string) (*Proxy, error) {
+	u, err := url.Parse(backend)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("Backend %q has no host", backend)
+	}
+	p := &Proxy{Addr: addr, Backend: u, ready: make(chan struct{})}
+	close(p.ready)
+	p.rp = httputil.NewSingleHostReverseProxy(u)
+	p.tr = &http.Transport{DialContext: p.dial}
+	p.rp.Transport = p.tr
+	p.rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		p.errorPage(w, http.StatusBadGateway, "Server unavailable", []byte(err.Error()))
+	}
+	return p, nil
+}
+
+func (p *Proxy) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return defaultHold
+}
+
+// Start listens on Addr and serves requests in the background
+func (p *Proxy) Start() error {
+	return p.bg.start(p.Addr, p)
+}
+
+// Stop closes the listener and any open connections
+func (p *Proxy) Stop() error {
+	return p.bg.stop()
+}
+
+// Hold holds new requests until Release is called
+func (p *Proxy) Hold() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.ready:
+		p.ready = make(chan struct{})
+	default: // already held
+	}
+}
+
+// Release lets held requests through
+// If failure is not empty requests get an error page showing it until the next successful Release
+func (p *Proxy) Release(failure []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failure = failure
+	p.tr.CloseIdleConnections() // connections to the old server
+	select {
+	case <-p.ready:
+	default:
+		close(p.ready)
+	}
+}
+
+// ServeHTTP forwards r to the Backend once no rebuild is in progress
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	ready := p.ready
+	p.mu.Unlock()
+	select {
+	case <-ready:
+	case <-time.After(p.timeout()):
+		p.errorPage(w, http.StatusServiceUnavailable, "Server restart timed out", nil)
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	p.mu.Lock()
+	failure := p.failure
+	p.mu.Unlock()
+	if len(failure) > 0 {
+		p.errorPage(w, http.StatusInternalServerError, "Build failed", failure)
+		return
+	}
+	p.rp.ServeHTTP(w, r)
+}
+
+// dial retries until the Backend accepts connections, it may still be starting up
+func (p *Proxy) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := net.Dialer{Timeout: time.Second}
+	deadline := time.Now().Add(p.timeout())
+	for {
+		c, err := d.DialContext(ctx, network, addr)
+		if err == nil || time.Now().After(deadline) {
+			return c, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+var errorTmpl = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif">
+<h1 style="color: #c00">{{.Title}}</h1>
+{{if .Output}}<pre style="background: #f4f4f4; padding: 1em; overflow: auto">{{.Output}}</pre>{{end}}
+</body>
+</html>
+`))
+
+func (p *Proxy) errorPage(w http.ResponseWriter, status int, title string, output []byte) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	errorTmpl.Execute(w, struct {
+		Title  string
+		Output string
+	}{title, string(output)})
+}
+
+type proxyTask struct {
+	p     *Proxy
+	tasks []goauto.Tasker
+}
+
+// NewProxyTask returns a Task that holds the requests to p while running tasks, i.e. a build followed by a restart
+// The tasks are run in order as they would be in a Workflow, TaskInfo.Target is left at the last Target they set
+// and the Targets before it are added to TaskInfo.Collect
+// If a task fails its output and error are shown by p until tasks succeed
+// Stop is passed to any task that is a goauto.Stopper
+func NewProxyTask(p *Proxy, tasks ...goauto.Tasker) goauto.Tasker {
+	return &proxyTask{p: p, tasks: tasks}
+}
+
+func (pt *proxyTask) Run(info *goauto.TaskInfo) (err error) {
+	pt.p.Hold()
+	var out bytes.Buffer
+	tout, terr := info.Tout, info.Terr
+	info.Tout, info.Terr = io.MultiWriter(tout, &out), io.MultiWriter(terr, &out)
+	defer func() {
+		info.Tout, info.Terr = tout, terr
+		if err != nil {
+			fmt.Fprintln(&out, err)
+			pt.p.Release(out.Bytes())
+			return
+		}
+		pt.p.Release(nil)
+	}()
+	// the Workflow adds the last Target to Collect, only the ones before it are added here
+	var last string
+	for _, t := range pt.tasks {
+		info.Target = ""
+		if err = t.Run(info); err != nil {
+			return
+		}
+		if info.Target != "" {
+			if last != "" {
+				info.Collect = append(info.Collect, last)
+			}
+			info.Src, last = info.Target, info.Target
+		}
+	}
+	info.Target = last
+	return
+}
+
+// Stop stops the tasks that are Stoppers
+func (pt *proxyTask) Stop() (err error) {
+	for _, t := range pt.tasks {
+		if s, ok := t.(goauto.Stopper); ok {
+			if serr := s.Stop(); serr != nil && err == nil {
+				err = serr
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,174 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/goauto"
+)
+
+func get(p *Proxy) (int, string) {
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/hello", nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello from ", r.URL.Path)
+	}))
+	defer backend.Close()
+	p, err := NewProxy("127.0.0.1:0", backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, body := get(p); code != 200 || body != "hello from /hello" {
+		t.Errorf("Unexpected response %v %q", code, body)
+	}
+
+	p.Hold()
+	res := make(chan string)
+	go func() {
+		_, body := get(p)
+		res <- body
+	}()
+	select {
+	case <-res:
+		t.Fatal("Expected request to be held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	p.Release(nil)
+	if body := <-res; body != "hello from /hello" {
+		t.Errorf("Unexpected held response %q", body)
+	}
+
+	p.Release([]byte("main.go:3: undefined: <x>"))
+	if code, body := get(p); code != 500 || !strings.Contains(body, "main.go:3: undefined: &lt;x&gt;") {
+		t.Errorf("Expected build error page got %v %q", code, body)
+	}
+
+	p.Timeout = 50 * time.Millisecond
+	p.Hold()
+	if code, _ := get(p); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected timeout got %v", code)
+	}
+}
+
+func TestProxyStartingBackend(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	p, err := NewProxy("127.0.0.1:0", "http://"+addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server comes up after the request arrives
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "up")
+		}))
+	}()
+	if code, body := get(p); code != 200 || body != "up" {
+		t.Errorf("Expected request to wait for the server got %v %q", code, body)
+	}
+}
+
+func TestProxyTask(t *testing.T) {
+	p, err := NewProxy(":0", "http://localhost:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ran []string
+	build := goauto.NewTask(goauto.Identity, func(i *goauto.TaskInfo) error {
+		ran = append(ran, i.Src)
+		fmt.Fprintln(i.Terr, "./main.go:10: syntax error")
+		return errors.New("exit status 2")
+	})
+	ti := &goauto.TaskInfo{Src: "main.go", Tout: ioutil.Discard, Terr: ioutil.Discard}
+	if err = NewProxyTask(p, build).Run(ti); err == nil {
+		t.Error("Expected build error")
+	}
+	if code, body := get(p); code != 500 || !strings.Contains(body, "./main.go:10: syntax error\nexit status 2") {
+		t.Errorf("Expected build output got %v %q", code, body)
+	}
+	if ti.Terr != ioutil.Discard {
+		t.Error("Expected Terr to be restored")
+	}
+
+	ok := goauto.NewTask(func(s string) string { return s + ".out" }, func(i *goauto.TaskInfo) error {
+		ran = append(ran, i.Src)
+		return nil
+	})
+	if err = NewProxyTask(p, ok, ok).Run(ti); err != nil {
+		t.Error(err)
+	}
+	if len(p.failure) != 0 {
+		t.Error("Expected failure cleared")
+	}
+	if s := strings.Join(ran, ","); s != "main.go,main.go,main.go.out" {
+		t.Errorf("Expected tasks run in order got %v", s)
+	}
+
+	// in a Workflow each Target is collected once
+	var collect []string
+	last := goauto.NewTask(goauto.Identity, func(i *goauto.TaskInfo) error {
+		collect = i.Collect
+		return nil
+	})
+	none := goauto.NewTask(func(string) string { return "" }, func(*goauto.TaskInfo) error { return nil })
+	for _, pt := range []goauto.Tasker{NewProxyTask(p, ok, ok), NewProxyTask(p, ok, ok, none)} {
+		goauto.NewWorkflow(pt, last).Run(&goauto.TaskInfo{Src: "main.go", Tout: ioutil.Discard, Terr: ioutil.Discard})
+		if s := strings.Join(collect, ","); s != "main.go,main.go.out,main.go.out.out" {
+			t.Errorf("Expected each Target collected once got %v", s)
+		}
+	}
+}
+
+func TestProxyService(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer backend.Close()
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+
+	p, _ := NewProxy(addr, backend.URL)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "ok" {
+		t.Errorf("Expected ok got %q", b)
+	}
+	if err = p.Stop(); err != nil {
+		t.Error(err)
+	}
+	if _, err = http.Get("http://" + addr + "/"); err == nil {
+		t.Error("Expected Proxy to be stopped")
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// toRGBA returns a copy of img as premultiplied RGBA with its origin at 0,0
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// filterWeights are the source pixels and their weights for one destination pixel
+type filterWeights struct {
+	start int
+	w     []float32
+}
+
+// catmullRom is the Catmull-Rom cubic filter
+func catmullRom(x float64) float64 {
+	x = math.Abs(x)
+	if x < 1 {
+		return (1.5*x-2.5)*x*x + 1
+	}
+	if x < 2 {
+		return ((-0.5*x+2.5)*x-4)*x + 2
+	}
+	return 0
+}
+
+// weights returns the filter weights to scale in pixels to out pixels
+// The filter is widened when shrinking so every source pixel contributes
+func weights(in, out int) []filterWeights {
+	scale := float64(in) / float64(out)
+	width := math.Max(scale, 1)
+	ws := make([]filterWeights, out)
+	for i := range ws {
+		center := (float64(i)+0.5)*scale - 0.5
+		lo := int(math.Ceil(center - 2*width))
+		hi := int(math.Floor(center + 2*width))
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > in-1 {
+			hi = in - 1
+		}
+		fw := filterWeights{start: lo, w: make([]float32, hi-lo+1)}
+		sum := 0.0
+		for j := lo; j <= hi; j++ {
+			w := catmullRom((float64(j) - center) / width)
+			fw.w[j-lo] = float32(w)
+			sum += w
+		}
+		if sum != 0 {
+			for k := range fw.w {
+				fw.w[k] /= float32(sum)
+			}
+		}
+		ws[i] = fw
+	}
+	return ws
+}
+
+// resize returns src scaled to w x h
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw == w && sh == h {
+		return src
+	}
+	// scale the rows then the columns
+	tmp := make([]float32, w*sh*4)
+	for x, fw := range weights(sw, w) {
+		for y := 0; y < sh; y++ {
+			var p [4]float32
+			row := src.Pix[y*src.Stride:]
+			for k, wt := range fw.w {
+				o := (fw.start + k) * 4
+				p[0] += wt * float32(row[o])
+				p[1] += wt * float32(row[o+1])
+				p[2] += wt * float32(row[o+2])
+				p[3] += wt * float32(row[o+3])
+			}
+			copy(tmp[(y*w+x)*4:], p[:])
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y, fw := range weights(sh, h) {
+		for x := 0; x < w; x++ {
+			var p [4]float32
+			for k, wt := range fw.w {
+				o := ((fw.start+k)*w + x) * 4
+				p[0] += wt * tmp[o]
+				p[1] += wt * tmp[o+1]
+				p[2] += wt * tmp[o+2]
+				p[3] += wt * tmp[o+3]
+			}
+			// the filter overshoots at edges, keep the colors premultiplied
+			a := clamp8(p[3], 255)
+			o := y*dst.Stride + x*4
+			dst.Pix[o] = clamp8(p[0], a)
+			dst.Pix[o+1] = clamp8(p[1], a)
+			dst.Pix[o+2] = clamp8(p[2], a)
+			dst.Pix[o+3] = a
+		}
+	}
+	return dst
+}
+
+// clamp8 rounds v to 0..max
+func clamp8(v float32, max uint8) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= float32(max) {
+		return max
+	}
+	return uint8(v + 0.5)
+}
+
+// fitSize returns the size of a sw x sh image scaled to fit within w x h without enlarging it
+// A zero w or h leaves that side unconstrained
+func fitSize(sw, sh, w, h int) (int, int) {
+	scale := 1.0
+	if w > 0 {
+		scale = math.Min(scale, float64(w)/float64(sw))
+	}
+	if h > 0 {
+		scale = math.Min(scale, float64(h)/float64(sh))
+	}
+	return scaled(sw, scale), scaled(sh, scale)
+}
+
+// cropRect returns the centered part of a sw x sh image with the aspect ratio of w x h
+func cropRect(sw, sh, w, h int) image.Rectangle {
+	cw, ch := sw, sh
+	if sw*h > sh*w {
+		cw = scaled(sh, float64(w)/float64(h))
+	} else {
+		ch = scaled(sw, float64(h)/float64(w))
+	}
+	x, y := (sw-cw)/2, (sh-ch)/2
+	return image.Rect(x, y, x+cw, y+ch)
+}
+
+func scaled(n int, scale float64) int {
+	if s := int(math.Floor(float64(n)*scale + 0.5)); s > 1 {
+		return s
+	}
+	return 1
+}
+
+// orient returns img turned upright for an EXIF orientation from 1 to 8
+func orient(img *image.RGBA, o int) *image.RGBA {
+	if o < 2 || o > 8 {
+		return img
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch o {
+			case 2: // flip horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotate 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // flip vertically
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 90 counter clockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], img.Pix[sy*img.Stride+sx*4:])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG file or 1 if it has none
+func jpegOrientation(b []byte) int {
+	if len(b) < 4 || b[0] != 0xFF || b[1] != 0xD8 {
+		return 1
+	}
+	for p := 2; p+4 <= len(b) && b[p] == 0xFF; {
+		marker := b[p+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan or end of image
+			break
+		}
+		end := p + 2 + (int(b[p+2])<<8 | int(b[p+3]))
+		if end < p+4 {
+			break
+		}
+		if end > len(b) {
+			end = len(b)
+		}
+		if seg := b[p+4 : end]; marker == 0xE1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		p = end
+	}
+	return 1
+}
+
+// tiffOrientation returns the orientation tag of the first IFD of TIFF data
+func tiffOrientation(t []byte) int {
+	var bo binary.ByteOrder
+	switch {
+	case len(t) < 8:
+		return 1
+	case string(t[:2]) == "II":
+		bo = binary.LittleEndian
+	case string(t[:2]) == "MM":
+		bo = binary.BigEndian
+	default:
+		return 1
+	}
+	off := int64(bo.Uint32(t[4:]))
+	if off+2 > int64(len(t)) {
+		return 1
+	}
+	n := int64(bo.Uint16(t[off:]))
+	for e := off + 2; e < off+2+n*12 && e+12 <= int64(len(t)); e += 12 {
+		if bo.Uint16(t[e:]) == 0x0112 {
+			if o := int(bo.Uint16(t[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
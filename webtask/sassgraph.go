@@ -0,0 +1,278 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/goauto"
+)
+
+var (
+	sassComments = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+	sassRule     = regexp.MustCompile(`@(?:import|use|forward)\s+([^;{]+)`)
+	sassString   = regexp.MustCompile(`"([^"]+)"|'([^']+)'`)
+	// dart-sass reports   main.scss 3:10  root stylesheet
+	sassDartLoc = regexp.MustCompile(`(?m)^\s+(\S+\.s[ac]ss) (\d+):\d+\s`)
+	// ruby sass and libsass report on line 3 of main.scss
+	sassLineLoc = regexp.MustCompile(`on line (\d+)(?::\d+)? of (\S+\.s[ac]ss)`)
+	sassMessage = regexp.MustCompile(`(?m)^Error: (.*)$`)
+)
+
+// A SassGraph tracks the @import, @use and @forward dependencies between Sass files
+// so that a change to a partial can be mapped to the entry stylesheets that include it
+type SassGraph struct {
+	LoadPaths []string // Additional directories to resolve imports from
+	mu        sync.Mutex
+	deps      map[string][]string // file to the files it imports
+	scanned   map[string]bool
+}
+
+// NewSassGraph returns an empty SassGraph, see Scan
+func NewSassGraph(loadPaths ...string) *SassGraph {
+	return &SassGraph{LoadPaths: loadPaths, deps: make(map[string][]string), scanned: make(map[string]bool)}
+}
+
+// IsSass reports whether fpath is a .scss or .sass file
+func IsSass(fpath string) bool {
+	ext := filepath.Ext(fpath)
+	return ext == ".scss" || ext == ".sass"
+}
+
+// IsPartial reports whether fpath is a Sass partial such as _sub.scss
+func IsPartial(fpath string) bool {
+	return strings.HasPrefix(filepath.Base(fpath), "_")
+}
+
+// Scan adds all of the Sass files in dir and its sub directories to the graph
+func (g *SassGraph) Scan(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.scanned[dir] = true
+	g.mu.Unlock()
+	return filepath.Walk(dir, func(f string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if f != dir && goauto.IsHidden(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if IsSass(f) {
+			return g.Update(f)
+		}
+		return nil
+	})
+}
+
+// Update parses fpath and records its imports, a missing file is removed from the graph
+func (g *SassGraph) Update(fpath string) error {
+	fpath, err := filepath.Abs(fpath)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(fpath)
+	if os.IsNotExist(err) {
+		g.mu.Lock()
+		delete(g.deps, fpath)
+		g.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var deps []string
+	for _, imp := range sassImports(b) {
+		if d := g.resolve(filepath.Dir(fpath), imp); d != "" {
+			deps = append(deps, d)
+		}
+	}
+	g.mu.Lock()
+	g.deps[fpath] = deps
+	g.mu.Unlock()
+	return nil
+}
+
+// Entries returns the stylesheets that are not partials and include fpath, directly or through other files
+// fpath itself is returned if it is not a partial
+func (g *SassGraph) Entries(fpath string) []string {
+	fpath, err := filepath.Abs(fpath)
+	if err != nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	users := make(map[string][]string)
+	for f, deps := range g.deps {
+		for _, d := range deps {
+			users[d] = append(users[d], f)
+		}
+	}
+	var entries []string
+	seen := map[string]bool{fpath: true}
+	queue := []string{fpath}
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		if !IsPartial(f) {
+			entries = append(entries, f)
+		}
+		for _, u := range users[f] {
+			if !seen[u] {
+				seen[u] = true
+				queue = append(queue, u)
+			}
+		}
+	}
+	sort.Strings(entries)
+	return entries
+}
+
+// sassImports returns the file names imported by a Sass source
+// plain CSS imports, urls and built in modules are skipped
+func sassImports(src []byte) (imps []string) {
+	src = sassComments.ReplaceAll(src, nil)
+	for _, m := range sassRule.FindAllSubmatch(src, -1) {
+		for _, s := range sassString.FindAllSubmatch(m[1], -1) {
+			imp := string(s[1])
+			if imp == "" {
+				imp = string(s[2])
+			}
+			if strings.HasSuffix(imp, ".css") || strings.HasPrefix(imp, "sass:") || strings.Contains(imp, "://") || strings.HasPrefix(imp, "url(") {
+				continue
+			}
+			imps = append(imps, imp)
+		}
+	}
+	return
+}
+
+// resolve returns the file imp refers to from dir or the LoadPaths, or "" if not found
+func (g *SassGraph) resolve(dir, imp string) string {
+	for _, base := range append([]string{dir}, g.LoadPaths...) {
+		p := filepath.Join(base, filepath.FromSlash(imp))
+		d, n := filepath.Split(p)
+		var cands []string
+		if IsSass(n) {
+			cands = []string{p, filepath.Join(d, "_"+n)}
+		} else {
+			for _, ext := range []string{".scss", ".sass"} {
+				cands = append(cands, p+ext, filepath.Join(d, "_"+n+ext),
+					filepath.Join(p, "_index"+ext), filepath.Join(p, "index"+ext))
+			}
+		}
+		for _, c := range cands {
+			if fi, err := os.Stat(c); err == nil && !fi.IsDir() {
+				if a, err := filepath.Abs(c); err == nil {
+					return a
+				}
+				return c
+			}
+		}
+	}
+	return ""
+}
+
+type sassFileTask struct {
+	cmd       string
+	args      []string
+	transform goauto.Transformer
+	graph     *SassGraph
+}
+
+// NewSassFileTask returns a Task that compiles only the stylesheets affected by a change to TaskInfo.Src
+// A changed partial is mapped to the entry stylesheets that include it using graph and each of them
+// is compiled by the sass command line utility to transform(entry) with args
+// The directory of TaskInfo.Src is scanned the first time it is seen, use graph.Scan for other source directories
+// Compile errors are reported as file:line: message
+// TaskInfo.Target is set to the last compiled file, the others are added to TaskInfo.Collect
+func NewSassFileTask(transform goauto.Transformer, graph *SassGraph, args ...string) goauto.Tasker {
+	return &sassFileTask{cmd: "sass", args: args, transform: transform, graph: graph}
+}
+
+func (st *sassFileTask) Run(info *goauto.TaskInfo) (err error) {
+	info.Buf.Reset()
+	info.Target = info.Src
+	dir, err := filepath.Abs(filepath.Dir(info.Src))
+	if err != nil {
+		return
+	}
+	st.graph.mu.Lock()
+	scanned := st.graph.scanned[dir]
+	st.graph.mu.Unlock()
+	if !scanned {
+		if err = st.graph.Scan(dir); err != nil {
+			return
+		}
+	}
+	if err = st.graph.Update(info.Src); err != nil {
+		return
+	}
+
+	entries := st.graph.Entries(info.Src)
+	for i, entry := range entries {
+		target := st.transform(entry)
+		if err = st.compile(info, entry, target); err != nil {
+			return
+		}
+		if i < len(entries)-1 {
+			info.Collect = append(info.Collect, target)
+		}
+		info.Target = target
+	}
+	return
+}
+
+func (st *sassFileTask) compile(info *goauto.TaskInfo, src, target string) error {
+	t0 := time.Now()
+	args := append([]string(nil), st.args...)
+	for _, lp := range st.graph.LoadPaths {
+		args = append(args, "--load-path", lp)
+	}
+	args = append(args, src, target)
+	var stderr bytes.Buffer
+	cmd := exec.Command(st.cmd, args...)
+	cmd.Stdout = &info.Buf
+	cmd.Stderr = io.MultiWriter(info.Terr, &stderr)
+	err := cmd.Run()
+	info.Tout.Write(info.Buf.Bytes())
+	if err != nil {
+		return sassError(src, stderr.Bytes(), err)
+	}
+	if info.Verbose {
+		fmt.Fprintf(info.Tout, "<< sass %v %v %v\n", src, target, time.Since(t0))
+	}
+	return nil
+}
+
+// sassError returns an error with the file and line of a sass compile error if they can be found
+func sassError(src string, out []byte, err error) error {
+	msg := err.Error()
+	if m := sassMessage.FindSubmatch(out); m != nil {
+		msg = string(m[1])
+	}
+	if m := sassDartLoc.FindSubmatch(out); m != nil {
+		return fmt.Errorf("%s:%s: %v", m[1], m[2], msg)
+	}
+	if m := sassLineLoc.FindSubmatch(out); m != nil {
+		return fmt.Errorf("%s:%s: %v", m[2], m[1], msg)
+	}
+	return fmt.Errorf("%v: %v", src, msg)
+}
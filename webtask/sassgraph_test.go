@@ -0,0 +1,155 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dshills/goauto"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for n, c := range files {
+		p := filepath.Join(dir, filepath.FromSlash(n))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSassImports(t *testing.T) {
+	src := `// @import "commented";
+/* @use "also/commented"; */
+@use "sass:math";
+@use 'theme' as t;
+@import "reset.css", "a", 'b';
+@import url(foo.css);
+@forward "lib/index";
+`
+	exp := []string{"theme", "a", "b", "lib/index"}
+	if imps := sassImports([]byte(src)); !reflect.DeepEqual(imps, exp) {
+		t.Errorf("Expected %v got %v", exp, imps)
+	}
+}
+
+func TestSassGraph(t *testing.T) {
+	g := NewSassGraph()
+	if err := g.Scan("../testing"); err != nil {
+		t.Fatal(err)
+	}
+	main, _ := filepath.Abs("../testing/main.scss")
+	if e := g.Entries("../testing/_sub.scss"); !reflect.DeepEqual(e, []string{main}) {
+		t.Errorf("Expected main.scss for _sub.scss got %v", e)
+	}
+
+	dir, err := ioutil.TempDir("", "goauto-sass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"site.scss":               `@use "components";`,
+		"admin.scss":              `@import "vars", "shared/mixins";`,
+		"print.scss":              `body { color: black; }`,
+		"_vars.scss":              `$c: red;`,
+		"components/_index.scss":  `@forward "button";`,
+		"components/_button.scss": `@use "../vars";`,
+		"lib/shared/_mixins.sass": `=m`,
+	})
+	g = NewSassGraph(filepath.Join(dir, "lib"))
+	if err = g.Scan(dir); err != nil {
+		t.Fatal(err)
+	}
+	abs := func(ns ...string) (ps []string) {
+		for _, n := range ns {
+			ps = append(ps, filepath.Join(dir, n))
+		}
+		return
+	}
+	tests := []struct {
+		file    string
+		entries []string
+	}{
+		{"_vars.scss", abs("admin.scss", "site.scss")},
+		{"components/_button.scss", abs("site.scss")},
+		{"lib/shared/_mixins.sass", abs("admin.scss")},
+		{"print.scss", abs("print.scss")},
+	}
+	for _, tt := range tests {
+		if e := g.Entries(filepath.Join(dir, tt.file)); !reflect.DeepEqual(e, tt.entries) {
+			t.Errorf("Expected %v for %v got %v", tt.entries, tt.file, e)
+		}
+	}
+
+	os.Remove(filepath.Join(dir, "admin.scss"))
+	g.Update(filepath.Join(dir, "admin.scss"))
+	if e := g.Entries(filepath.Join(dir, "_vars.scss")); !reflect.DeepEqual(e, abs("site.scss")) {
+		t.Errorf("Expected removed file dropped got %v", e)
+	}
+}
+
+func TestSassError(t *testing.T) {
+	dart := "Error: Undefined variable.\n  ╷\n3 │   color: $nope;\n  │          ^^^^^\n  ╵\n  css/main.scss 3:10  root stylesheet\n"
+	if err := sassError("main.scss", []byte(dart), errors.New("exit status 65")); err.Error() != "css/main.scss:3: Undefined variable." {
+		t.Errorf("Unexpected dart-sass error %v", err)
+	}
+	ruby := "Error: Invalid CSS after \"a\": expected \"{\", was \"\"\n        on line 7:2 of _sub.scss\n"
+	if err := sassError("main.scss", []byte(ruby), errors.New("exit status 1")); err.Error() != `_sub.scss:7: Invalid CSS after "a": expected "{", was ""` {
+		t.Errorf("Unexpected libsass error %v", err)
+	}
+	if err := sassError("main.scss", nil, errors.New("exit status 1")); err.Error() != "main.scss: exit status 1" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestSassFileTask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-sass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// stands in for sass, copies src to target or fails like dart-sass
+	writeFiles(t, dir, map[string]string{
+		"bin/sass": `#!/bin/sh
+for a; do src=$tgt; tgt=$a; done
+if grep -q nope "$src"; then
+	printf 'Error: Undefined variable.\n\n  %s 1:8  root stylesheet\n' "$src" >&2
+	exit 65
+fi
+cp "$src" "$tgt"
+`,
+		"a.scss":     `@import "vars";`,
+		"b.scss":     `@import "vars";`,
+		"_vars.scss": `$c: red;`,
+	})
+	os.Chmod(filepath.Join(dir, "bin", "sass"), 0755)
+
+	tsk := NewSassFileTask(goauto.ExtTransformer("css"), NewSassGraph()).(*sassFileTask)
+	tsk.cmd = filepath.Join(dir, "bin", "sass")
+	ti := &goauto.TaskInfo{Src: filepath.Join(dir, "_vars.scss"), Tout: ioutil.Discard, Terr: ioutil.Discard}
+	if err = tsk.Run(ti); err != nil {
+		t.Fatal(err)
+	}
+	if ti.Target != filepath.Join(dir, "b.css") || !reflect.DeepEqual(ti.Collect, []string{filepath.Join(dir, "a.css")}) {
+		t.Errorf("Expected a.css and b.css got %v %v", ti.Target, ti.Collect)
+	}
+	for _, n := range []string{"a.css", "b.css"} {
+		if _, err = os.Stat(filepath.Join(dir, n)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	writeFiles(t, dir, map[string]string{"a.scss": `a { color: $nope; }`})
+	ti = &goauto.TaskInfo{Src: filepath.Join(dir, "a.scss"), Tout: ioutil.Discard, Terr: ioutil.Discard}
+	err = tsk.Run(ti)
+	if err == nil || err.Error() != filepath.Join(dir, "a.scss")+":1: Undefined variable." {
+		t.Errorf("Expected file:line error got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// server runs an http.Handler in the background for the Services in this package
+type server struct {
+	mu  sync.Mutex
+	srv *http.Server
+}
+
+// start listens on addr and serves h in the background
+func (s *server) start(addr string, h http.Handler) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.srv = &http.Server{Handler: h}
+	srv := s.srv
+	s.mu.Unlock()
+	go srv.Serve(l)
+	return nil
+}
+
+// stop closes the listener and any open connections
+func (s *server) stop() error {
+	s.mu.Lock()
+	srv := s.srv
+	s.srv = nil
+	s.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Close()
+}
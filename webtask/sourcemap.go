@@ -0,0 +1,178 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+const base64Digits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// A sourceMap builds a version 3 source map
+type sourceMap struct {
+	sources []string
+	segs    []segment
+}
+
+// segment maps a generated line and column to a line and column in a source, all zero based
+type segment struct {
+	genLine, genCol, src, srcLine, srcCol int
+}
+
+// addSource adds a source file name and returns its index
+func (m *sourceMap) addSource(name string) int {
+	m.sources = append(m.sources, name)
+	return len(m.sources) - 1
+}
+
+func (m *sourceMap) add(s segment) {
+	m.segs = append(m.segs, s)
+}
+
+// encode returns the JSON source map for the generated file
+func (m *sourceMap) encode(file string) ([]byte, error) {
+	var b []byte
+	line, prevCol, prevSrc, prevLine, prevSrcCol := 0, 0, 0, 0, 0
+	for i, s := range m.segs {
+		for line < s.genLine {
+			b = append(b, ';')
+			line++
+			prevCol = 0
+		}
+		if i > 0 && len(b) > 0 && b[len(b)-1] != ';' {
+			b = append(b, ',')
+		}
+		b = vlq(b, s.genCol-prevCol)
+		b = vlq(b, s.src-prevSrc)
+		b = vlq(b, s.srcLine-prevLine)
+		b = vlq(b, s.srcCol-prevSrcCol)
+		prevCol, prevSrc, prevLine, prevSrcCol = s.genCol, s.src, s.srcLine, s.srcCol
+	}
+	sources := m.sources
+	if sources == nil {
+		sources = []string{}
+	}
+	return json.Marshal(struct {
+		Version  int      `json:"version"`
+		File     string   `json:"file"`
+		Sources  []string `json:"sources"`
+		Names    []string `json:"names"`
+		Mappings string   `json:"mappings"`
+	}{3, file, sources, []string{}, string(b)})
+}
+
+// vlq appends the base64 VLQ encoding of v to b
+func vlq(b []byte, v int) []byte {
+	u := v << 1
+	if v < 0 {
+		u = (-v << 1) | 1
+	}
+	for {
+		digit := u & 31
+		u >>= 5
+		if u > 0 {
+			digit |= 32
+		}
+		b = append(b, base64Digits[digit])
+		if u == 0 {
+			return b
+		}
+	}
+}
+
+// An emitter collects generated output and records source map segments for it
+type emitter struct {
+	out       []byte
+	line, col int // position of the end of out
+	sm        *sourceMap
+	src       int   // index of the current source in sm
+	lines     []int // offsets of the start of each line of the current source
+}
+
+// source sets the source that following marks refer to
+func (e *emitter) source(src []byte, idx int) {
+	if e.sm == nil {
+		return
+	}
+	e.src = idx
+	e.lines = []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			e.lines = append(e.lines, i+1)
+		}
+	}
+}
+
+// mark maps the current output position to offset off of the current source
+func (e *emitter) mark(off int) {
+	if e.sm == nil {
+		return
+	}
+	l := sort.Search(len(e.lines), func(i int) bool { return e.lines[i] > off }) - 1
+	e.sm.add(segment{e.line, e.col, e.src, l, off - e.lines[l]})
+}
+
+func (e *emitter) write(b []byte) {
+	for _, c := range b {
+		if c == '\n' {
+			e.line++
+			e.col = 0
+		} else {
+			e.col++
+		}
+	}
+	e.out = append(e.out, b...)
+}
+
+func (e *emitter) writeByte(c byte) {
+	e.write([]byte{c})
+}
+
+// last returns the last byte written or 0
+func (e *emitter) last() byte {
+	if len(e.out) == 0 {
+		return 0
+	}
+	return e.out[len(e.out)-1]
+}
+
+// mapComment returns the format of the sourceMappingURL comment for target or "" if it can not have a source map
+func mapComment(target string) string {
+	switch filepath.Ext(target) {
+	case ".css":
+		return "\n/*# sourceMappingURL=%v */\n"
+	case ".js", ".mjs":
+		return "\n//# sourceMappingURL=%v\n"
+	}
+	return ""
+}
+
+// mapSource returns the name of src in the source map of target
+func mapSource(target, src string) string {
+	rel, err := filepath.Rel(filepath.Dir(target), src)
+	if err != nil {
+		return filepath.ToSlash(src)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// writeFile writes the output to target along with target.map if there is a source map
+func (e *emitter) writeFile(target string) error {
+	if e.sm != nil {
+		mapFile := target + ".map"
+		b, err := e.sm.encode(filepath.Base(target))
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(mapFile, b, 0644); err != nil {
+			return err
+		}
+		e.write([]byte(fmt.Sprintf(mapComment(target), filepath.Base(mapFile))))
+	}
+	return ioutil.WriteFile(target, e.out, 0644)
+}
@@ -0,0 +1,165 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dshills/goauto"
+)
+
+// template: page.html:3: msg, template: page.html:3:10: msg or html/template:page.html:2:5: msg
+var templateErr = regexp.MustCompile(`^(?:html/)?template: ?([^:\s]+):(\d+)(?::\d+)?: (.*)`)
+
+// A TemplateTask checks Go html/templates when they change so errors are found before the server restarts
+// Each page is parsed with the Layouts and Funcs and then executed, which also checks the html escaping
+// Without sample Data only escaping errors are reported
+type TemplateTask struct {
+	Pages    string   // Glob pattern of the page templates, i.e. templates/*.html
+	Layouts  []string // Glob patterns of the layouts and partials parsed with every page
+	Funcs    template.FuncMap
+	Entry    string             // Name of the template to execute, defaults to the page, i.e. base.html for a page that defines content
+	Data     interface{}        // Sample data to execute the pages with, missing map keys are errors
+	DataFile goauto.Transformer // Returns a JSON file of sample data for a page, i.e. page.html.json, used if it exists
+}
+
+// NewTemplateTask returns a TemplateTask for the pages matching the glob pattern pages and the layouts
+// A changed page is checked on its own, a change to a layout checks every page
+// goauto.TaskInfo.Target is set to goauto.TaskInfo.Src
+func NewTemplateTask(pages string, layouts ...string) *TemplateTask {
+	return &TemplateTask{Pages: pages, Layouts: layouts}
+}
+
+// Run will check the templates affected by the change to TaskInfo.Src
+func (tt *TemplateTask) Run(info *goauto.TaskInfo) (err error) {
+	info.Target = info.Src
+	info.Buf.Reset()
+	layouts, err := globAll(tt.Layouts)
+	if err != nil {
+		return
+	}
+	src, err := filepath.Abs(info.Src)
+	if err != nil {
+		return
+	}
+	pages := []string{src}
+	for _, l := range layouts {
+		if l == src {
+			if pages, err = globAll([]string{tt.Pages}); err != nil {
+				return
+			}
+			break
+		}
+	}
+
+	var errs []string
+	for _, p := range pages {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			continue // removed
+		}
+		if err := tt.check(p, layouts); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	if info.Verbose {
+		fmt.Fprintf(info.Tout, "<< %v templates ok\n", len(pages))
+	}
+	return
+}
+
+// check parses and executes the page
+func (tt *TemplateTask) check(page string, layouts []string) error {
+	files := []string{page}
+	for _, l := range layouts {
+		if l != page {
+			files = append(files, l)
+		}
+	}
+	t, err := template.New(filepath.Base(page)).Funcs(tt.Funcs).ParseFiles(files...)
+	if err != nil {
+		return templateError(err, files)
+	}
+	data, err := tt.data(page)
+	if err != nil {
+		return err
+	}
+	entry := tt.Entry
+	if entry == "" {
+		entry = filepath.Base(page)
+	}
+	err = t.Option("missingkey=error").ExecuteTemplate(ioutil.Discard, entry, data)
+	var escErr *template.Error
+	if err != nil && (data != nil || errors.As(err, &escErr)) {
+		return templateError(err, files)
+	}
+	return nil
+}
+
+// data returns the sample data for page
+func (tt *TemplateTask) data(page string) (interface{}, error) {
+	if tt.DataFile != nil {
+		b, err := ioutil.ReadFile(tt.DataFile(page))
+		if err == nil {
+			var d interface{}
+			if err = json.Unmarshal(b, &d); err != nil {
+				return nil, fmt.Errorf("%v: %v", tt.DataFile(page), err)
+			}
+			return d, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return tt.Data, nil
+}
+
+// templateError rewrites a template error as file:line: message using the full path of the file
+func templateError(err error, files []string) error {
+	m := templateErr.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	name := m[1]
+	for _, f := range files {
+		if filepath.Base(f) == name {
+			name = f
+			break
+		}
+	}
+	return fmt.Errorf("%v:%v: %v", name, m[2], m[3])
+}
+
+// globAll returns the absolute paths of the files matching patterns
+func globAll(patterns []string) (files []string, err error) {
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		m, err := filepath.Glob(p)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(m)
+		for _, f := range m {
+			if f, err = filepath.Abs(f); err != nil {
+				return nil, err
+			}
+			if !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,91 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package webtask
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/goauto"
+)
+
+func TestTemplateTask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"layouts/base.html": "<html>{{template \"content\" .}}</html>",
+		"index.html":        "{{define \"content\"}}<p>{{upper .Title}}</p>{{end}}",
+		"about.html":        "{{define \"content\"}}\n<p>{{.About}}</p>{{end}}",
+		"about.html.json":   `{"About": "us"}`,
+	})
+	p := func(n string) string { return filepath.Join(dir, filepath.FromSlash(n)) }
+
+	tt := NewTemplateTask(p("*.html"), p("layouts/*.html"))
+	tt.Entry = "base.html"
+	tt.DataFile = func(f string) string { return f + ".json" }
+	run := func(src string) error {
+		return tt.Run(&goauto.TaskInfo{Src: src, Tout: ioutil.Discard, Terr: ioutil.Discard})
+	}
+
+	// upper is not registered
+	err = run(p("index.html"))
+	if err == nil || !strings.HasPrefix(err.Error(), p("index.html")+":1: ") || !strings.Contains(err.Error(), `"upper" not defined`) {
+		t.Errorf("Expected an undefined function error, got %v", err)
+	}
+	tt.Funcs = template.FuncMap{"upper": strings.ToUpper}
+	if err = run(p("index.html")); err != nil {
+		t.Error(err)
+	}
+	if err = run(p("about.html")); err != nil {
+		t.Error(err)
+	}
+
+	// sample data is missing a field
+	writeFiles(t, dir, map[string]string{"about.html.json": `{"Team": "us"}`})
+	err = run(p("about.html"))
+	if err == nil || !strings.HasPrefix(err.Error(), p("about.html")+":2:") || !strings.Contains(err.Error(), "About") {
+		t.Errorf("Expected a missing key error, got %v", err)
+	}
+	writeFiles(t, dir, map[string]string{"about.html.json": `{"About": "us"}`})
+
+	// a broken layout is reported for every page
+	writeFiles(t, dir, map[string]string{"layouts/base.html": "<html>\n{{if .}}{{template \"content\" .}}</html>"})
+	err = run(p("layouts/base.html"))
+	if err == nil || strings.Count(err.Error(), p("layouts/base.html")+":2: ") != 2 {
+		t.Errorf("Expected an error for both pages, got %v", err)
+	}
+}
+
+func TestTemplateEscape(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goauto-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"ok.html":  "<p>{{.Name}}</p>",
+		"bad.html": "<a href=\"{{if .}}x\">{{else}}y{{end}}\">",
+	})
+	tt := NewTemplateTask(filepath.Join(dir, "*.html"))
+	info := &goauto.TaskInfo{Src: filepath.Join(dir, "ok.html"), Tout: ioutil.Discard, Terr: ioutil.Discard}
+	// without sample data only escaping is checked
+	if err = tt.Run(info); err != nil {
+		t.Error(err)
+	}
+	if info.Target != info.Src {
+		t.Errorf("Expected Target %v got %v", info.Src, info.Target)
+	}
+	info = &goauto.TaskInfo{Src: filepath.Join(dir, "bad.html"), Tout: ioutil.Discard, Terr: ioutil.Discard}
+	err = tt.Run(info)
+	if err == nil || !strings.HasPrefix(err.Error(), filepath.Join(dir, "bad.html")+":1: ") {
+		t.Errorf("Expected an escaping error, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ package goauto
 import (
 	"fmt"
 	"regexp"
+	"sync"
 	"time"
 )
 
@@ -13,9 +14,33 @@ import (
 type Workflow struct {
 	Name       string
 	Concurrent bool
+	Priority   int // Concurrent Workflows with a higher Priority run first when a Pipeline limits MaxWorkers
 	Op         Op
 	Regexs     []*regexp.Regexp
+	Ignores    []*regexp.Regexp
 	Tasks      []Tasker
+	chains     []chain
+	mu         sync.Mutex
+	status     WorkflowStatus
+	disabled   bool
+	policy     PausePolicy
+	held       *TaskInfo
+}
+
+// A WorkflowStatus describes the current or last run of a Workflow
+type WorkflowStatus struct {
+	Src      string        // File name the Workflow ran for
+	Start    time.Time     // Zero if the Workflow has not run
+	Duration time.Duration // Time taken by the last completed run
+	Running  bool
+	Err      error // Error returned by the failed task if any
+}
+
+// Status returns the status of the current or last run of the Workflow
+func (wf *Workflow) Status() WorkflowStatus {
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	return wf.status
 }
 
 // NewWorkflow returns a Workflow with tasks
@@ -43,38 +68,56 @@ func (wf *Workflow) WatchPattern(patterns ...string) error {
 	return nil
 }
 
+// IgnorePattern adds one or more regex for files this workflow should never match
+// i.e. editor backup files ~$
+// An invalid regexp pattern will return an error
+func (wf *Workflow) IgnorePattern(patterns ...string) error {
+	for _, p := range patterns {
+		r, err := regexp.Compile(p)
+		if err != nil {
+			return err
+		}
+		wf.Ignores = append(wf.Ignores, r)
+	}
+	return nil
+}
+
 // WatchOp sets the file operations to match
 // The default is Create | Write | Remove | Rename
 func (wf *Workflow) WatchOp(op Op) {
 	wf.Op = op
 }
 
+// matchOp returns true if op includes one of the operations of the Workflow
 func (wf *Workflow) matchOp(op Op) bool {
-	switch {
-	case wf.Op&Create == op&Create:
-		return true
-	case wf.Op&Write == op&Write:
-		return true
-	case wf.Op&Remove == op&Remove:
-		return true
-	case wf.Op&Rename == op&Rename:
-		return true
-	case wf.Op&Chmod == op&Chmod:
-		return true
-	}
-	return false
+	return wf.Op&op != 0
 }
 
 // Match checks a file name against the regexp of the Workflow and the file operation
 func (wf *Workflow) Match(fpath string, op Op) bool {
-	if wf.matchOp(op) {
-		for _, r := range wf.Regexs {
-			if r.MatchString(fpath) {
-				return true
-			}
+	m, _ := wf.Explain(fpath, op)
+	return m
+}
+
+// Explain reports whether a file name and operation match the Workflow and why
+func (wf *Workflow) Explain(fpath string, op Op) (match bool, reason string) {
+	if !wf.matchOp(op) {
+		return false, fmt.Sprintf("op %v is not one of %v", op, wf.Op)
+	}
+	for _, r := range wf.Ignores {
+		if r.MatchString(fpath) {
+			return false, fmt.Sprintf("ignored by %v", r)
+		}
+	}
+	for _, r := range wf.Regexs {
+		if r.MatchString(fpath) {
+			return true, fmt.Sprintf("matched %v", r)
 		}
 	}
-	return false
+	if len(wf.Regexs) == 0 {
+		return false, "no patterns to match"
+	}
+	return false, fmt.Sprintf("no pattern matched %v", wf.Regexs)
 }
 
 // Add adds a task to the workflow
@@ -85,15 +128,37 @@ func (wf *Workflow) Add(tasks ...Tasker) {
 }
 
 func (wf *Workflow) runner(info *TaskInfo) {
+	fname := info.Src
+	t0 := time.Now()
+	wf.mu.Lock()
+	wf.status = WorkflowStatus{Src: fname, Start: t0, Running: true}
+	wf.mu.Unlock()
+
+	err := wf.runTasks(info)
+
+	wf.mu.Lock()
+	wf.status = WorkflowStatus{Src: fname, Start: t0, Duration: time.Since(t0), Err: err}
+	wf.mu.Unlock()
+	wf.fire(info, fname, err)
+	info.pass.end(wf, fname, err)
+	info.pipeline.end()
+}
+
+func (wf *Workflow) runTasks(info *TaskInfo) (err error) {
 	if info.Verbose {
 		fmt.Fprintf(info.Tout, ">> %v %v for %v\n\n", time.Now().Format("2006/01/02 3:04pm"), wf.Name, info.Src)
 	}
 	fname := info.Src
-	info.Collect = []string{fname}
-	var err error
+	if len(info.Collect) == 0 {
+		// chained workflows start with the Collect of the workflows that triggered them
+		info.Collect = []string{fname}
+	}
 	for _, t := range wf.Tasks {
 		info.Target = "" // reset the Target
-		if err = t.Run(info); err != nil {
+		release := info.pipeline.acquire(t)
+		err = t.Run(info)
+		release()
+		if err != nil {
 			fmt.Fprintln(info.Terr, err)
 			fmt.Fprintf(info.Terr, "Fail! Workflow %v did not complete for %v\n\n\n", wf.Name, fname)
 			return
@@ -104,12 +169,19 @@ func (wf *Workflow) runner(info *TaskInfo) {
 			info.Collect = append(info.Collect, info.Target)
 		}
 	}
+	return
 }
 
 // Run will start the execution of tasks
+// Disabled Workflows hold the run until enabled (See Disable)
 func (wf *Workflow) Run(info *TaskInfo) {
+	if wf.hold(info) {
+		return
+	}
+	info.pass.begin()
+	info.pipeline.begin()
 	if wf.Concurrent {
-		go wf.runner(info)
+		info.pipeline.spawn(wf.Priority, func() { wf.runner(info) })
 		return
 	}
 	wf.runner(info)
@@ -23,3 +23,35 @@ func TestNewWorkflow(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestWorkflowMatchOp(t *testing.T) {
+	wf := NewWorkflow()
+	wf.WatchPattern(`\.go$`)
+	if wf.Match("a.go", Chmod) {
+		t.Errorf("Expected Chmod not to match the default ops")
+	}
+	if !wf.Match("a.go", Write|Chmod) {
+		t.Errorf("Expected Write|Chmod to match")
+	}
+	wf.WatchOp(Create)
+	if wf.Match("a.go", Write) {
+		t.Errorf("Expected Write not to match Create")
+	}
+	if m, r := wf.Explain("a.go", Write); m || r != "op Write is not one of Create" {
+		t.Errorf("Unexpected explanation %v %q", m, r)
+	}
+}
+
+func TestWorkflowIgnorePattern(t *testing.T) {
+	wf := NewWorkflow()
+	wf.WatchPattern(`\.go$`)
+	if err := wf.IgnorePattern(`_skip`, "[bad"); err == nil {
+		t.Errorf("Expected error for bad regexp")
+	}
+	if !wf.Match("a.go", Write) || wf.Match("a_skip.go", Write) {
+		t.Errorf("Expected a.go to match and a_skip.go to be ignored")
+	}
+	if m, r := wf.Explain("a_skip.go", Write); m || r != "ignored by _skip" {
+		t.Errorf("Unexpected explanation %v %q", m, r)
+	}
+}
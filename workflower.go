@@ -11,3 +11,9 @@ type Workflower interface {
 	Match(fpath string, op Op) bool
 	Run(*TaskInfo)
 }
+
+// An Explainer is a Workflower that can explain why it does or does not match a file
+// Used by Pipeline.DryRun
+type Explainer interface {
+	Explain(fpath string, op Op) (match bool, reason string)
+}